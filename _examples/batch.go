@@ -82,9 +82,14 @@ func BatchWrite(c gs7.Client, logger logging.Logger) {
 	data = append(data, gs7.DateTime(time.Now()).ToBytes())
 	data = append(data, gs7.DateTimeLong(time.Now()).ToBytes())
 	data = append(data, gs7.S5Time(time.Duration(80)*time.Millisecond).ToBytes())
-	data = append(data, gs7.String("batch read").ToBytes(c.GetPduLength()))
+	s, err := gs7.String("batch read").Encode(c.GetPduLength(), c.GetCharset())
+	if err != nil {
+		logger.Errorf("Failed to encode string, error: %s", err)
+		return
+	}
+	data = append(data, s)
 	data = append(data, gs7.WString("批处理写入").ToBytes(c.GetPduLength()))
-	err := c.WriteRawBatch(addresses, data).Wait()
+	err = c.WriteRawBatch(addresses, data).Wait()
 	if err != nil {
 		logger.Errorf("Failed to batch read, error: %s", err)
 		return
@@ -0,0 +1,65 @@
+package _examples
+
+import (
+	"time"
+
+	"github.com/shiyuecamus/gs7"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/logging"
+)
+
+func main() {
+	const (
+		host = "192.168.0.1"
+		port = 102
+		rack = 0
+		slot = 1
+	)
+	logger := logging.GetDefaultLogger()
+
+	c := gs7.NewClientBuilder().
+		PlcType(common.S1500).
+		Host(host).
+		Port(port).
+		Rack(rack).
+		Slot(slot).
+		Build()
+
+	if _, err := c.Connect().Wait(); err != nil {
+		logger.Errorf("Failed to connect PLC, host: %s, port: %d, error: %s", host, port, err)
+		return
+	}
+	defer c.Disconnect()
+
+	// values are encoded by the type of the address
+	addresses := []string{"DB1.X0.0", "DB1.I4", "DB1.R16", "DB1.T20", "DB1.DT30", "DB1.S52", "DB1.REAL100[4]"}
+	values := []any{true, int16(88), float32(355.538), 355 * time.Millisecond, time.Now(), "test string!!", []float32{1, 2, 3, 4}}
+	if err := c.WriteBatchParsed(addresses, values).Wait(); err != nil {
+		logger.Errorf("Failed to write, error: %s", err)
+		return
+	}
+
+	v, err := c.ReadBatchParsed(addresses).Wait()
+	if err != nil {
+		logger.Errorf("Failed to read, error: %s", err)
+		return
+	}
+	for i, address := range addresses {
+		logger.Infof("%s: %s", address, v[i])
+	}
+
+	// typed read
+	f, err := gs7.Read[float32](c, "DB1.R16")
+	if err != nil {
+		logger.Errorf("Failed to read real, error: %s", err)
+		return
+	}
+	logger.Infof("DB1.R16: %f", f)
+
+	reals, err := gs7.Read[[]float32](c, "DB1.REAL100[4]")
+	if err != nil {
+		logger.Errorf("Failed to read reals, error: %s", err)
+		return
+	}
+	logger.Infof("DB1.REAL100[4]: %v", reals)
+}
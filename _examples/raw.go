@@ -520,7 +520,12 @@ func StringRwAndParse(c gs7.Client, logger logging.Logger) {
 	}
 	logger.Infof("read string raw success, bytes: %x, parsed value: %s", wait, b)
 
-	_ = c.WriteRaw("DB1.STRING52", gs7.String("test string!!").ToBytes(c.GetPduLength())).Wait()
+	s, err := gs7.String("test string!!").Encode(c.GetPduLength(), c.GetCharset())
+	if err != nil {
+		logger.Errorf("Failed to encode string, error: %s", err)
+		return
+	}
+	_ = c.WriteRaw("DB1.STRING52", s).Wait()
 
 	wait, err = c.ReadRaw("DB1.S52").Wait()
 	if err != nil {
@@ -0,0 +1,57 @@
+package _examples
+
+import (
+	"github.com/shiyuecamus/gs7"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"github.com/shiyuecamus/gs7/logging"
+)
+
+func main() {
+	const (
+		host = "192.168.0.1"
+		port = 102
+		rack = 0
+		slot = 1
+	)
+	logger := logging.GetDefaultLogger()
+
+	// PLC tags exported from TIA Portal (.xlsx or .csv)
+	tags, err := core.TagTableFromFile("PLCTags.xlsx")
+	if err != nil {
+		logger.Errorf("Failed to import tag table, error: %s", err)
+		return
+	}
+
+	c := gs7.NewClientBuilder().
+		PlcType(common.S1500).
+		Host(host).
+		Port(port).
+		Rack(rack).
+		Slot(slot).
+		TagTable(tags).
+		Build()
+
+	if _, err := c.Connect().Wait(); err != nil {
+		logger.Errorf("Failed to connect PLC, host: %s, port: %d, error: %s", host, port, err)
+		return
+	}
+	defer c.Disconnect()
+
+	names := tags.Names()
+	v, err := c.ReadTags(names).Wait()
+	if err != nil {
+		logger.Errorf("Failed to read tags, error: %s", err)
+		return
+	}
+	for i, name := range names {
+		logger.Infof("%s: %s", name, v[i])
+	}
+
+	// tag names can also be used in place of addresses
+	err = c.WriteRaw("Pump1_Run", gs7.Bit(true).ToBytes()).Wait()
+	if err != nil {
+		logger.Errorf("Failed to write tag, error: %s", err)
+		return
+	}
+}
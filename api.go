@@ -22,32 +22,56 @@ type Client interface {
 	Disconnect()
 	// GetPduLength return plc max pdu length
 	GetPduLength() int
+	// GetCharset return charset of STRING, CHAR arrays and block info names
+	GetCharset() common.Charset
 
 	// ReadRaw read raw bytes from address
-	ReadRaw(address string) *SingleRawReadToken
+	ReadRaw(address string, opts ...CallOption) *SingleRawReadToken
 	// ReadBatchRaw read batch raw bytes from addresses
-	ReadBatchRaw(addresses []string) *BatchRawReadToken
+	ReadBatchRaw(addresses []string, opts ...CallOption) *BatchRawReadToken
 	// ReadParsed read auto parsed data from address
-	ReadParsed(address string) *SingleParsedReadToken
+	ReadParsed(address string, opts ...CallOption) *SingleParsedReadToken
 	// ReadBatchParsed read batch auto parsed data from address
-	ReadBatchParsed(addresses []string) *BatchParsedReadToken
+	ReadBatchParsed(addresses []string, opts ...CallOption) *BatchParsedReadToken
 	// WriteRaw write raw bytes to plc address
-	WriteRaw(address string, data []byte) *SimpleToken
+	WriteRaw(address string, data []byte, opts ...CallOption) *SimpleToken
 	// WriteRawBatch write batch raw bytes to plc addresses
-	WriteRawBatch(addresses []string, data [][]byte) *SimpleToken
+	// contiguous byte writes are merged, the order is given by the write mode, see WithWriteMode
+	// with WithVerify the addresses are read back and compared after the write
+	// with WithRollback the original data is written back if any address fails
+	WriteRawBatch(addresses []string, data [][]byte, opts ...CallOption) *SimpleToken
+	// WriteParsed write value to address, the value is encoded by the type of the address,
+	// e.g. float32 for DB1.REAL0, time.Time for DB1.DT0, []int16 for DB1.INT0[10]
+	WriteParsed(address string, value any, opts ...CallOption) *SimpleToken
+	// WriteBatchParsed write batch values to addresses, encoded by the type of each address
+	WriteBatchParsed(addresses []string, values []any, opts ...CallOption) *SimpleToken
+	// ReadBatchRawResults read batch raw bytes from addresses with result of each address
+	// A failed address, e.g. a missing data block, does not fail the others,
+	// the error of the token is only set when the request can not be done at all, e.g. connection lost
+	ReadBatchRawResults(addresses []string, opts ...CallOption) *BatchResultToken
+	// ReadBatchParsedResults read batch auto parsed data from addresses with result of each address
+	ReadBatchParsedResults(addresses []string, opts ...CallOption) *BatchResultToken
+	// WriteRawBatchResults write batch raw bytes to addresses with result of each address
+	WriteRawBatchResults(addresses []string, data [][]byte, opts ...CallOption) *BatchResultToken
+	// WriteBatchParsedResults write batch values to addresses with result of each address
+	WriteBatchParsedResults(addresses []string, values []any, opts ...CallOption) *BatchResultToken
+	// ReadTags read auto parsed data of tags from tag table
+	ReadTags(names []string, opts ...CallOption) *BatchParsedReadToken
+	// WriteTags write raw bytes to tags from tag table
+	WriteTags(names []string, data [][]byte, opts ...CallOption) *SimpleToken
 	// BaseRead block read
 	// Support exceeds the maximum pdu length.
 	// If the maximum pdu length is exceeded, it will be divided into multiple requests
 	// And the aggregated results will be returned after the last request
-	BaseRead(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, size int) *BaseReadToken
+	BaseRead(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, size int, opts ...CallOption) *BaseReadToken
 	// BaseWrite block write
 	// Support exceeds the maximum pdu length.
 	// If the maximum pdu length is exceeded, it will be divided into multiple requests
-	BaseWrite(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, data []byte) *SimpleToken
+	BaseWrite(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, data []byte, opts ...CallOption) *SimpleToken
 	// DBGet get all data of the data block
-	DBGet(dbNumber int) *BaseReadToken
+	DBGet(dbNumber int, opts ...CallOption) *BaseReadToken
 	// DBFill fill the data block to the specified byte
-	DBFill(dbNumber int, fillByte byte) *SimpleToken
+	DBFill(dbNumber int, fillByte byte, opts ...CallOption) *SimpleToken
 
 	// HotRestart Puts the CPU in run mode performing and hot start.
 	HotRestart() *SimpleToken
@@ -62,9 +86,9 @@ type Client interface {
 	// InsertFile insert file
 	InsertFile(blockType common.BlockType, blockNumber int) *SimpleToken
 	// UploadFile upload file content from PLC to PC
-	UploadFile(blockType common.BlockType, blockNumber int) *UploadToken
+	UploadFile(blockType common.BlockType, blockNumber int, opts ...CallOption) *UploadToken
 	// DownloadFile download file content from PC to PLC
-	DownloadFile(bytes []byte, blockType common.BlockType, blockNumber int, mC7CodeLength int) *SimpleToken
+	DownloadFile(bytes []byte, blockType common.BlockType, blockNumber int, mC7CodeLength int, opts ...CallOption) *SimpleToken
 	// ClockRead read plc clock
 	ClockRead() *ClockReadToken
 	// ClockSet set plc clock
@@ -0,0 +1,224 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"errors"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultBackoffJitter part of the delay cut off at random by the default connect and reconnect backoff
+const DefaultBackoffJitter = 0.5
+
+// BackoffPolicy delay between the attempts of a connect, reconnect or request retry
+type BackoffPolicy interface {
+	// Next delay before the next attempt after failures failed attempts in a row, starting at 1, false to give up
+	Next(failures int) (time.Duration, bool)
+	// Done outcome of an attempt, for policies with state across calls, e.g. CircuitBreaker
+	Done(err error)
+}
+
+// ConstantBackoff the same delay before each attempt
+type ConstantBackoff struct {
+	Interval time.Duration
+	// MaxAttempts attempts including the first, 0 or negative unlimited
+	MaxAttempts int
+}
+
+func (b ConstantBackoff) Next(failures int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && failures >= b.MaxAttempts {
+		return 0, false
+	}
+	return b.Interval, true
+}
+
+func (b ConstantBackoff) Done(error) {}
+
+// ExponentialBackoff delay doubled after each failed attempt, starting at Initial, capped at Max
+type ExponentialBackoff struct {
+	Initial time.Duration
+	// Max cap of the delay, 0 uncapped
+	Max time.Duration
+	// MaxAttempts attempts including the first, 0 or negative unlimited
+	MaxAttempts int
+}
+
+func (b ExponentialBackoff) Next(failures int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && failures >= b.MaxAttempts {
+		return 0, false
+	}
+	delay := b.Initial
+	for i := 1; i < failures && (b.Max <= 0 || delay < b.Max) && delay < time.Duration(1)<<62; i++ {
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay, true
+}
+
+func (b ExponentialBackoff) Done(error) {}
+
+// JitterBackoff delay of Policy shortened by a random part up to Factor,
+// so clients failing at the same time, e.g. after a plant-wide power restore, do not retry in lockstep
+type JitterBackoff struct {
+	Policy BackoffPolicy
+	// Factor max part of the delay cut off, between 0 and 1
+	Factor float64
+}
+
+func (b JitterBackoff) Next(failures int) (time.Duration, bool) {
+	delay, ok := b.Policy.Next(failures)
+	if !ok || delay <= 0 {
+		return delay, ok
+	}
+	factor := min(max(b.Factor, 0), 1)
+	return delay - time.Duration(rand.Float64()*factor*float64(delay)), true
+}
+
+func (b JitterBackoff) Done(err error) {
+	b.Policy.Done(err)
+}
+
+// CircuitBreaker opens after Threshold failed attempts in a row, counted across all calls sharing it,
+// the attempts wait until Cooldown has passed, then they are let through staggered by the delays of Policy,
+// not limited to a single probe. A success closes it again. Delays of closed state are those of Policy
+type CircuitBreaker struct {
+	m         sync.Mutex
+	policy    BackoffPolicy
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+func NewCircuitBreaker(policy BackoffPolicy, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{policy: policy, threshold: max(threshold, 1), cooldown: cooldown}
+}
+
+func (b *CircuitBreaker) Next(failures int) (time.Duration, bool) {
+	delay, ok := b.policy.Next(failures)
+	if !ok {
+		return 0, false
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	if wait := time.Until(b.openUntil); wait > 0 {
+		// the attempts of all calls wait for the cooldown, each a delay after the previous one
+		b.openUntil = b.openUntil.Add(delay)
+		return wait, true
+	}
+	return delay, true
+}
+
+func (b *CircuitBreaker) Done(err error) {
+	b.policy.Done(err)
+	b.m.Lock()
+	defer b.m.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold && time.Now().After(b.openUntil) {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Open the breaker is open, attempts are held back
+func (b *CircuitBreaker) Open() bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// withRetry call fn until it succeeds or the policy gives up, only errors accepted by retriable are retried.
+// every outcome is reported to the policy, the attempts made are returned
+func withRetry(policy BackoffPolicy, retriable func(err error, failures int) bool, fn func() error) (int, error) {
+	for failures := 1; ; failures++ {
+		err := fn()
+		policy.Done(err)
+		if err == nil {
+			return failures, nil
+		}
+		if !retriable(err, failures) {
+			return failures, err
+		}
+		delay, ok := policy.Next(failures)
+		if !ok {
+			return failures, err
+		}
+		time.Sleep(delay)
+	}
+}
+
+// dial connect to the endpoint with the backoff policy between the attempts, the attempts made are returned
+func (c *client) dial(endpoint string, policy BackoffPolicy) (conn net.Conn, attempts int, err error) {
+	attempts, err = withRetry(policy, func(err error, failures int) bool {
+		c.logger.Debugf("attempt [%d] failed to connect to %s: %v", failures, endpoint, err)
+		return true
+	}, func() (err error) {
+		conn, err = c.dialOnce(endpoint)
+		return
+	})
+	if err != nil {
+		return nil, attempts, err
+	}
+	c.setKeepAlive(conn)
+	return conn, attempts, nil
+}
+
+// transmitRetry transmit the request, retried by the retry backoff after timeouts and missing resources of plc.
+// the attempts are started by timers, no goroutine waits between them, each attempt with a new pdu reference
+func (c *client) transmitRetry(request *core.PDU, priority Priority) *PduToken {
+	if c.retryBackoff == nil {
+		return c.transmit(request, priority)
+	}
+	p := NewToken(TtPdu).(*PduToken)
+	done := func(ack *core.PDU, err error) {
+		p.v, p.err = ack, err
+		p.flowComplete()
+	}
+	var attempt func(failures int)
+	attempt = func(failures int) {
+		c.transmit(request, priority).then(func(ack *core.PDU, err error) {
+			c.retryBackoff.Done(err)
+			if err == nil || !retriable(err) {
+				done(ack, err)
+				return
+			}
+			reference := request.GetHeader().GetPduReference()
+			c.logger.Debugf("attempt [%d] of request [%d] failed: %v", failures, reference, err)
+			delay, ok := c.retryBackoff.Next(failures)
+			if !ok {
+				done(ack, err)
+				return
+			}
+			time.AfterFunc(delay, func() {
+				// a late answer to the failed attempt must not be taken for the answer of the next one
+				c.tcpClient.removeRequestId(reference)
+				request.GetHeader().SetPduReference(c.GeneratePduNumber())
+				attempt(failures + 1)
+			})
+		})
+	}
+	attempt(1)
+	return p
+}
+
+// retriable the request may succeed when sent again: timeout or missing resources of plc
+func retriable(err error) bool {
+	if errors.Is(err, common.ErrTcpRequestTimeout) {
+		return true
+	}
+	var e *common.Error
+	return errors.As(err, &e) && e.ErrorClass != 0x00 && common.IsResourceError(e.ErrorClass, e.S7ErrorCode)
+}
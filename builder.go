@@ -6,7 +6,9 @@ package gs7
 
 import (
 	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
 	"github.com/shiyuecamus/gs7/logging"
+	"github.com/shiyuecamus/gs7/metrics"
 	"github.com/shiyuecamus/gs7/util"
 	"sync"
 	"time"
@@ -26,7 +28,7 @@ type ClientBuilder struct {
 	// autoReconnect reconnect on connection lost
 	// default value false
 	autoReconnect bool
-	// reconnectInterval maximum connection reconnect interval.
+	// reconnectInterval first reconnect interval of the default reconnect backoff.
 	// each attempt will be multiplied by 2
 	// default value 10s
 	reconnectInterval time.Duration
@@ -40,7 +42,7 @@ type ClientBuilder struct {
 	// connectRetry automatically retry when attempting to connect failed
 	// default value false
 	connectRetry bool
-	// retryInterval maximum connection retry interval.
+	// retryInterval first connection retry interval of the default connect backoff.
 	// each attempt will be multiplied by 2
 	// default value 10s
 	retryInterval time.Duration
@@ -53,10 +55,73 @@ type ClientBuilder struct {
 	maxRetryBackoff time.Duration
 	onConnected     func(c Client)
 	onUnActive      func(c Client, err error)
+	// tags plc tag table imported from TIA Portal
+	// tag names can be used instead of addresses
+	tags *core.TagTable
+	// charset encoding of STRING, CHAR arrays and block info names
+	// default value GBK
+	charset common.Charset
+	// readCoalesceGap maximum gap in bytes between addresses of the same area read as one range
+	// if set to negative, each address is read as own item
+	// default value 8
+	readCoalesceGap int
+	// writeMode default write mode of batch writes, can be overridden by WithWriteMode of each call
+	// default value WmOrdered
+	writeMode WriteMode
+	// verifyWrites read back after every write and compare byte for byte, can be overridden by WithVerify of each call
+	// default value false
+	verifyWrites bool
+	// priority default priority class of reads and writes, can be overridden by WithPriority of each call
+	// default value PrInteractive
+	priority Priority
+	// maxRequestsPerSecond max requests per second sent to plc, requests are spaced evenly
+	// default value 0, unlimited
+	maxRequestsPerSecond float64
+	// maxBytesPerSecond max bytes per second sent to and received from plc
+	// default value 0, unlimited
+	maxBytesPerSecond int
+	// adaptiveLoad halve the request rate when plc reports missing resources or the latency rises,
+	// and raise it slowly again up to maxRequestsPerSecond
+	// default value false
+	adaptiveLoad bool
+	// metrics record counts and latencies of operations, bytes, pdu splits, timeouts, rejects and reconnects
+	// e.g. metrics.NewPrometheus, default value metrics.Nop
+	metrics metrics.Metrics
+	// interceptors wrap the send of every request pdu, the first interceptor is the outermost.
+	// each intercepted request runs on a goroutine of its own, so they may block until the response
+	interceptors []Interceptor
+	// transport dial the connection and receive the frames of it, e.g. NewNetTransport, NewPipeTransport
+	// default value a gnet transport of the client, closed on disconnect
+	transport Transport
+	// engine runtime shared with other clients, its transport is used without Transport
+	// default value nil, a transport and workers of the client
+	engine *Engine
+	// dialer dial the connection to plc, e.g. NewSOCKS5Dialer, NewHTTPConnectDialer or the DialContext of an ssh client
+	// default value nil, dialed by the transport
+	dialer Dialer
+	// connectBackoff backoff between the connect attempts
+	// default value ExponentialBackoff of retryInterval, maxRetryBackoff and maxRetries with jitter
+	connectBackoff BackoffPolicy
+	// reconnectBackoff backoff between the reconnect attempts
+	// default value ExponentialBackoff of reconnectInterval, maxReconnectBackoff and maxReconnectTimes with jitter
+	reconnectBackoff BackoffPolicy
+	// retryBackoff backoff between the attempts of a request after a timeout or missing resources of plc
+	// default value nil, requests are not retried
+	// the attempts are started by timers, no goroutine waits for the delays
+	retryBackoff BackoffPolicy
+	// heartbeatInterval probe the connection with a one byte read when idle for the interval
+	// default value 0, disabled
+	heartbeatInterval time.Duration
+	// heartbeatMisses missed probes in a row before the connection is closed as dead and reconnected if auto reconnect
+	// default value 3
+	heartbeatMisses int
+	// keepAlive tcp keep-alive period of the connection, if set to negative, keep-alive is disabled
+	// default value 0, the default of go (15s)
+	keepAlive time.Duration
 }
 
 func NewClientBuilder() ClientBuilder {
-	return ClientBuilder{}
+	return ClientBuilder{readCoalesceGap: DefaultReadCoalesceGap, priority: PrInteractive}
 }
 
 func (b ClientBuilder) PlcType(plcType common.PlcType) ClientBuilder {
@@ -134,6 +199,106 @@ func (b ClientBuilder) MaxRetryBackoff(maxRetryBackoff time.Duration) ClientBuil
 	return b
 }
 
+func (b ClientBuilder) TagTable(tags *core.TagTable) ClientBuilder {
+	b.tags = tags
+	return b
+}
+
+func (b ClientBuilder) Charset(charset common.Charset) ClientBuilder {
+	b.charset = charset
+	return b
+}
+
+func (b ClientBuilder) ReadCoalesceGap(gap int) ClientBuilder {
+	b.readCoalesceGap = gap
+	return b
+}
+
+func (b ClientBuilder) WriteMode(mode WriteMode) ClientBuilder {
+	b.writeMode = mode
+	return b
+}
+
+func (b ClientBuilder) VerifyWrites(verify bool) ClientBuilder {
+	b.verifyWrites = verify
+	return b
+}
+
+func (b ClientBuilder) Priority(priority Priority) ClientBuilder {
+	b.priority = priority
+	return b
+}
+
+func (b ClientBuilder) MaxRequestsPerSecond(rate float64) ClientBuilder {
+	b.maxRequestsPerSecond = rate
+	return b
+}
+
+func (b ClientBuilder) MaxBytesPerSecond(rate int) ClientBuilder {
+	b.maxBytesPerSecond = rate
+	return b
+}
+
+func (b ClientBuilder) AdaptiveLoad(adaptive bool) ClientBuilder {
+	b.adaptiveLoad = adaptive
+	return b
+}
+
+func (b ClientBuilder) Metrics(m metrics.Metrics) ClientBuilder {
+	b.metrics = m
+	return b
+}
+
+func (b ClientBuilder) Interceptors(interceptors ...Interceptor) ClientBuilder {
+	b.interceptors = interceptors
+	return b
+}
+
+func (b ClientBuilder) Transport(transport Transport) ClientBuilder {
+	b.transport = transport
+	return b
+}
+
+func (b ClientBuilder) Engine(engine *Engine) ClientBuilder {
+	b.engine = engine
+	return b
+}
+
+func (b ClientBuilder) Dialer(dialer Dialer) ClientBuilder {
+	b.dialer = dialer
+	return b
+}
+
+func (b ClientBuilder) ConnectBackoff(policy BackoffPolicy) ClientBuilder {
+	b.connectBackoff = policy
+	return b
+}
+
+func (b ClientBuilder) ReconnectBackoff(policy BackoffPolicy) ClientBuilder {
+	b.reconnectBackoff = policy
+	return b
+}
+
+func (b ClientBuilder) RetryBackoff(policy BackoffPolicy) ClientBuilder {
+	b.retryBackoff = policy
+	return b
+}
+
+func (b ClientBuilder) Heartbeat(interval time.Duration) ClientBuilder {
+	b.heartbeatInterval = interval
+	return b
+}
+
+func (b ClientBuilder) HeartbeatMisses(misses int) ClientBuilder {
+	b.heartbeatMisses = misses
+	return b
+}
+
+func (b ClientBuilder) KeepAlive(period time.Duration) ClientBuilder {
+	b.keepAlive = period
+	return b
+}
+
 func (b ClientBuilder) Logger(logger logging.Logger) ClientBuilder {
 	b.logger = logger
 	return b
@@ -176,6 +341,38 @@ func (b ClientBuilder) Build() Client {
 		logger:              util.AnyOrDefault(b.logger, logging.GetDefaultLogger()).(logging.Logger),
 		onConnected:         b.onConnected,
 		onDisconnected:      b.onUnActive,
+		tags:                b.tags,
+		charset:             b.charset,
+		readCoalesceGap:     b.readCoalesceGap,
+		writeMode:           b.writeMode,
+		verifyWrites:        b.verifyWrites,
+		priority:            b.priority,
+		scheduler:           newScheduler(),
+		limiter:             newLimiter(b.maxRequestsPerSecond, b.maxBytesPerSecond, b.adaptiveLoad),
+		metrics:             util.AnyOrDefault(b.metrics, metrics.Nop{}).(metrics.Metrics),
+		interceptors:        b.interceptors,
+		heartbeatInterval:   b.heartbeatInterval,
+		heartbeatMisses:     util.IntOrDefault(b.heartbeatMisses, DefaultHeartbeatMisses),
+		keepAlive:           b.keepAlive,
+		retryBackoff:        b.retryBackoff,
+		transport:           b.transport,
+		dialer:              b.dialer,
+	}
+	s.connectBackoff = util.AnyOrDefault(b.connectBackoff, JitterBackoff{
+		Policy: ExponentialBackoff{Initial: s.retryInterval, Max: s.maxRetryBackoff, MaxAttempts: s.maxRetries},
+		Factor: DefaultBackoffJitter,
+	}).(BackoffPolicy)
+	s.reconnectBackoff = util.AnyOrDefault(b.reconnectBackoff, JitterBackoff{
+		Policy: ExponentialBackoff{Initial: s.reconnectInterval, Max: s.maxReconnectBackoff, MaxAttempts: s.maxReconnectTimes},
+		Factor: DefaultBackoffJitter,
+	}).(BackoffPolicy)
+	if b.engine != nil {
+		s.pool = b.engine.pool
+		if s.transport == nil {
+			s.transport = b.engine.transport
+		}
+	} else {
+		s.pool = newWorkerPool(DefaultClientWorkers)
 	}
 	return s.init()
 }
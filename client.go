@@ -5,13 +5,15 @@
 package gs7
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/panjf2000/gnet/v2"
 	"github.com/shiyuecamus/gs7/common"
 	"github.com/shiyuecamus/gs7/core"
 	"github.com/shiyuecamus/gs7/logging"
+	"github.com/shiyuecamus/gs7/metrics"
 	"github.com/shiyuecamus/gs7/util"
 	"math"
 	"net"
@@ -25,9 +27,16 @@ import (
 type client struct {
 	m         *sync.RWMutex
 	tcpClient *s7TcpClient
-	cli       *gnet.Client
-	conn      gnet.Conn
-	logger    logging.Logger
+	// transport dial the connection and receive the frames of it
+	transport Transport
+	// dialer dial the connection instead of the transport, nil the transport dials
+	dialer Dialer
+	// pool workers writing the requests and completing the responses, of the engine if shared
+	pool *workerPool
+	// ownTransport the transport is created by the client and closed on disconnect
+	ownTransport bool
+	conn         net.Conn
+	logger       logging.Logger
 	// timeout connect and read Timeout
 	// default value 5s
 	timeout time.Duration
@@ -69,28 +78,68 @@ type client struct {
 
 	pduIndex uint32
 	status   connectionStatus
+	// tags plc tag table, tag names are resolved before addresses
+	tags *core.TagTable
+	// charset encoding of STRING, CHAR arrays and block info names
+	charset common.Charset
+	// readCoalesceGap maximum gap in bytes between addresses read as one range, negative disables
+	readCoalesceGap int
+	// writeMode default write mode of batch writes
+	writeMode WriteMode
+	// verifyWrites read back and compare after writes
+	verifyWrites bool
+	// priority default priority of reads and writes
+	priority Priority
+	// scheduler schedule requests by priority on the connection
+	scheduler *scheduler
+	// limiter limit requests and bytes per second, nil if unlimited
+	limiter *limiter
+	// metrics record the operations of the client
+	metrics metrics.Metrics
+	// interceptors wrap the send of every request pdu
+	interceptors []Interceptor
+	// sendFunc send wrapped by the interceptors, nil without interceptors
+	sendFunc SendFunc
+	// connectBackoff backoff between the attempts of Connect
+	connectBackoff BackoffPolicy
+	// reconnectBackoff backoff between the attempts of reconnect
+	reconnectBackoff BackoffPolicy
+	// retryBackoff backoff between the attempts of a request, nil no retries
+	retryBackoff BackoffPolicy
+	// heartbeatInterval idle time before the connection is probed, 0 disables
+	heartbeatInterval time.Duration
+	// heartbeatMisses missed probes before the connection is dead
+	heartbeatMisses int
+	// keepAlive tcp keep-alive period of the connection, negative disables, 0 default of go
+	keepAlive time.Duration
+	// lastActivity unix nano of the last response
+	lastActivity atomic.Int64
+	// deadCause cause of the connection closed as dead, reported by tcpOnClose
+	deadCause error
 
 	onConnected    func(c Client)
 	onDisconnected func(c Client, err error)
 }
 
 func (c *client) init() *client {
-	options := make([]gnet.Option, 0)
-	options = append(options,
-		gnet.WithLogger(c.logger),
-		gnet.WithMulticore(true))
-	tcpClient := newTcpClient(c.logger, c.timeout,
-		c.tcpOnOpen, c.tcpOnClose, c.validate)
-	cli, _ := gnet.NewClient(tcpClient, options...)
-	_ = cli.Start()
-	c.tcpClient = tcpClient
-	c.cli = cli
+	if len(c.interceptors) > 0 {
+		c.sendFunc = chainInterceptors(c.interceptors, c.transmitWait)
+	}
+	if c.transport == nil {
+		transport, err := NewGnetTransport(c.logger)
+		if err != nil {
+			c.logger.Errorf("S7 client start gnet transport failed with error: %v, plain net transport is used", err)
+			transport = NewNetTransport()
+		}
+		c.transport, c.ownTransport = transport, true
+	}
+	c.tcpClient = newTcpClient(c.logger, c.metrics, c.timeout, c.tcpOnClose, c.validate)
 	return c
 }
 
-func (c *client) ReadParsed(address string) *SingleParsedReadToken {
+func (c *client) ReadParsed(address string, opts ...CallOption) *SingleParsedReadToken {
 	token := NewToken(TtSingleParsedRead).(*SingleParsedReadToken)
-	c.ReadBatchParsed([]string{address}).Async(func(v []any, err error) {
+	c.ReadBatchParsed([]string{address}, opts...).then(func(v []any, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -101,9 +150,9 @@ func (c *client) ReadParsed(address string) *SingleParsedReadToken {
 	return token
 }
 
-func (c *client) ReadBatchParsed(addresses []string) *BatchParsedReadToken {
+func (c *client) ReadBatchParsed(addresses []string, opts ...CallOption) *BatchParsedReadToken {
 	token := NewToken(TtBatchParsedRead).(*BatchParsedReadToken)
-	c.ReadBatchRaw(addresses).Async(func(v []RawInfo, err error) {
+	c.ReadBatchRaw(addresses, opts...).then(func(v []RawInfo, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -124,9 +173,9 @@ func (c *client) ReadBatchParsed(addresses []string) *BatchParsedReadToken {
 	return token
 }
 
-func (c *client) ReadRaw(address string) *SingleRawReadToken {
+func (c *client) ReadRaw(address string, opts ...CallOption) *SingleRawReadToken {
 	token := NewToken(TtSingleRawRead).(*SingleRawReadToken)
-	c.ReadBatchRaw([]string{address}).Async(func(v []RawInfo, err error) {
+	c.ReadBatchRaw([]string{address}, opts...).then(func(v []RawInfo, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -137,50 +186,349 @@ func (c *client) ReadRaw(address string) *SingleRawReadToken {
 	return token
 }
 
-func (c *client) ReadBatchRaw(addresses []string) *BatchRawReadToken {
+func (c *client) ReadBatchRaw(addresses []string, opts ...CallOption) *BatchRawReadToken {
 	token := NewToken(TtBatchRawRead).(*BatchRawReadToken)
-	items, ots, err := c.parseReadRequestItems(addresses)
+	c.parseReadRequestItems(addresses, c.callOptions(opts).priority, func(items []common.RequestItem, infos []RawInfo, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		c.read(items, opts...).then(func(v []*core.DataItem, err error) {
+			if err != nil {
+				token.setError(err)
+				return
+			}
+			for i, dataItem := range v {
+				infos[i].Value = dataItem.Data
+			}
+			token.v = infos
+			token.flowComplete()
+		})
+	})
+	return token
+}
+
+func (c *client) WriteRaw(address string, data []byte, opts ...CallOption) *SimpleToken {
+	return c.WriteRawBatch([]string{address}, [][]byte{data}, opts...)
+}
+
+func (c *client) WriteRawBatch(addresses []string, data [][]byte, opts ...CallOption) *SimpleToken {
+	token := NewToken(TtSimple).(*SimpleToken)
+	options := c.callOptions(opts)
+	c.parsesWriteRequestItems(addresses, data, options.priority, func(requests []common.RequestItem, dataItems []common.ResponseItem, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		c.writeRequests(requests, dataItems, options, writeCompleter(token, requests))
+	})
+	return token
+}
+
+func (c *client) WriteParsed(address string, value any, opts ...CallOption) *SimpleToken {
+	return c.WriteBatchParsed([]string{address}, []any{value}, opts...)
+}
+
+func (c *client) WriteBatchParsed(addresses []string, values []any, opts ...CallOption) *SimpleToken {
+	data, err := c.encodeValues(addresses, values)
 	if err != nil {
+		token := NewToken(TtSimple).(*SimpleToken)
+		token.setError(err)
+		return token
+	}
+	return c.WriteRawBatch(addresses, data, opts...)
+}
+
+func (c *client) ReadTags(names []string, opts ...CallOption) *BatchParsedReadToken {
+	if err := c.checkTags(names); err != nil {
+		token := NewToken(TtBatchParsedRead).(*BatchParsedReadToken)
+		token.setError(err)
+		return token
+	}
+	return c.ReadBatchParsed(names, opts...)
+}
+
+func (c *client) WriteTags(names []string, data [][]byte, opts ...CallOption) *SimpleToken {
+	if err := c.checkTags(names); err != nil {
+		token := NewToken(TtSimple).(*SimpleToken)
 		token.setError(err)
 		return token
 	}
-	c.read(items).Async(func(v []*core.DataItem, err error) {
+	return c.WriteRawBatch(names, data, opts...)
+}
+
+func (c *client) ReadBatchRawResults(addresses []string, opts ...CallOption) *BatchResultToken {
+	token := NewToken(TtBatchResult).(*BatchResultToken)
+	if len(addresses) == 0 {
+		token.setError(common.ErrorWithCode(common.ErrAddressEmpty))
+		return token
+	}
+	results := make([]ItemResult, len(addresses))
+	items := make([]common.RequestItem, len(addresses))
+	probes := make([]*lengthProbe, 0)
+	// probeOwners index of the result of each probe
+	probeOwners := make([]int, 0)
+	for i, address := range addresses {
+		results[i].Address = address
+		item, info, probe, err := c.parseReadRequestItem(address)
+		if err != nil {
+			results[i].ReturnCode = returnCodeOf(err)
+			results[i].Err = err
+			continue
+		}
+		if probe != nil {
+			probes = append(probes, probe)
+			probeOwners = append(probeOwners, i)
+		}
+		results[i].Raw = info
+		items[i] = item
+	}
+	priority := c.callOptions(opts).priority
+	c.readLengthProbes(probes, priority, func(errs []error, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		for i, e := range errs {
+			if e != nil {
+				results[probeOwners[i]].ReturnCode = returnCodeOf(e)
+				results[probeOwners[i]].Err = e
+			}
+		}
+		c.readResults(token, results, items, priority)
+	})
+	return token
+}
+
+// readResults read the items of the results not failed yet, the results are completed by the return codes
+func (c *client) readResults(token *BatchResultToken, results []ItemResult, items []common.RequestItem, priority Priority) {
+	requests := make([]common.RequestItem, 0, len(results))
+	// owners index of the result of each request item
+	owners := make([]int, 0, len(results))
+	for i := range results {
+		if results[i].Err == nil {
+			requests = append(requests, items[i])
+			owners = append(owners, i)
+		}
+	}
+	if len(requests) == 0 {
+		token.v = results
+		token.flowComplete()
+		return
+	}
+	c.readItems(requests, priority).then(func(v []*core.DataItem, err error) {
 		if err != nil {
 			token.setError(err)
 			return
 		}
-		res := make([]RawInfo, 0, len(v))
 		for i, dataItem := range v {
-			res = append(res, RawInfo{
-				Value:   dataItem.Data,
-				Type:    ots[i],
-				plcType: c.plcType,
-			})
+			result := &results[owners[i]]
+			result.ReturnCode = dataItem.ReturnCode
+			if dataItem.ReturnCode != common.RcSuccess {
+				result.Err = common.ReturnCodeError{ReturnCode: dataItem.ReturnCode, Address: result.Address}
+				continue
+			}
+			result.Raw.Value = dataItem.Data
 		}
-		token.v = res
+		token.v = results
+		token.flowComplete()
+	})
+}
+
+func (c *client) ReadBatchParsedResults(addresses []string, opts ...CallOption) *BatchResultToken {
+	token := NewToken(TtBatchResult).(*BatchResultToken)
+	c.ReadBatchRawResults(addresses, opts...).then(func(v []ItemResult, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		for i := range v {
+			if v[i].Err == nil {
+				v[i].Value, v[i].Err = v[i].Raw.Parse()
+			}
+		}
+		token.v = v
 		token.flowComplete()
 	})
 	return token
 }
 
-func (c *client) WriteRaw(address string, data []byte) *SimpleToken {
-	return c.WriteRawBatch([]string{address}, [][]byte{data})
+func (c *client) WriteRawBatchResults(addresses []string, data [][]byte, opts ...CallOption) *BatchResultToken {
+	if len(addresses) != len(data) {
+		token := NewToken(TtBatchResult).(*BatchResultToken)
+		token.setError(common.ErrorWithCode(common.ErrCliRequestDataDifferent))
+		return token
+	}
+	results := make([]ItemResult, len(addresses))
+	for i, address := range addresses {
+		results[i].Address = address
+	}
+	return c.writeResults(results, data, c.callOptions(opts))
 }
 
-func (c *client) WriteRawBatch(addresses []string, data [][]byte) *SimpleToken {
-	requests, dataItems, err := c.parsesWriteRequestItems(addresses, data)
-	if err != nil {
-		token := NewToken(TtSimple).(*SimpleToken)
-		token.setError(err)
+func (c *client) WriteBatchParsedResults(addresses []string, values []any, opts ...CallOption) *BatchResultToken {
+	if len(addresses) != len(values) {
+		token := NewToken(TtBatchResult).(*BatchResultToken)
+		token.setError(common.ErrorWithCode(common.ErrCliRequestDataDifferent))
+		return token
+	}
+	results := make([]ItemResult, len(addresses))
+	data := make([][]byte, len(addresses))
+	for i, address := range addresses {
+		results[i].Address = address
+		bs, err := c.encodeValues([]string{address}, []any{values[i]})
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		data[i] = bs[0]
+	}
+	return c.writeResults(results, data, c.callOptions(opts))
+}
+
+// writeResults write data of the results not failed yet, the results are completed by the return codes
+// in ordered mode nothing after the first failed result is written.
+// The max lengths of strings not declared by the addresses are read first, by one request for all of them
+func (c *client) writeResults(results []ItemResult, data [][]byte, options callOptions) *BatchResultToken {
+	token := NewToken(TtBatchResult).(*BatchResultToken)
+	if len(results) == 0 {
+		token.setError(common.ErrorWithCode(common.ErrAddressEmpty))
 		return token
 	}
-	return c.write(requests, dataItems)
+	abort := options.writeMode == WmOrdered || options.rollback
+	items := make([]writeItems, len(results))
+	probes := make([]*lengthProbe, 0)
+	// probeOwners index of the result of each probe
+	probeOwners := make([]int, 0)
+	for i := range results {
+		if results[i].Err != nil {
+			if abort {
+				break
+			}
+			continue
+		}
+		requests, dataItems, probe, err := c.parseWriteRequestItem(results[i].Address, data[i])
+		if err != nil {
+			results[i].ReturnCode = returnCodeOf(err)
+			results[i].Err = err
+			if abort {
+				break
+			}
+			continue
+		}
+		if probe != nil {
+			probes = append(probes, probe)
+			probeOwners = append(probeOwners, i)
+		}
+		items[i] = writeItems{requests: requests, dataItems: dataItems}
+	}
+	c.readLengthProbes(probes, options.priority, func(errs []error, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		for i, e := range errs {
+			if e != nil {
+				results[probeOwners[i]].ReturnCode = returnCodeOf(e)
+				results[probeOwners[i]].Err = e
+				if abort {
+					break
+				}
+			}
+		}
+		c.writeItemResults(token, results, items, options)
+	})
+	return token
+}
+
+// writeItems request and data items of a result, arrays may be split into several items
+type writeItems struct {
+	requests  []common.RequestItem
+	dataItems []common.ResponseItem
+}
+
+// writeItemResults write the items of the results not failed yet, the results are completed by the return codes
+// in ordered mode nothing after the first failed result is written
+func (c *client) writeItemResults(token *BatchResultToken, results []ItemResult, items []writeItems, options callOptions) {
+	requests := make([]common.RequestItem, 0, len(results))
+	dataItems := make([]common.ResponseItem, 0, len(results))
+	// owners index of the result of each request item
+	owners := make([]int, 0, len(results))
+	aborted := false
+	for i := range results {
+		if aborted {
+			if results[i].Err == nil {
+				results[i].Err = common.ErrorWithCode(common.ErrCliWriteAborted, results[i].Address)
+			}
+			continue
+		}
+		if results[i].Err != nil {
+			aborted = options.writeMode == WmOrdered || options.rollback
+			continue
+		}
+		requests = append(requests, items[i].requests...)
+		dataItems = append(dataItems, items[i].dataItems...)
+		for range items[i].requests {
+			owners = append(owners, i)
+		}
+		results[i].ReturnCode = common.RcSuccess
+	}
+	if aborted && options.rollback {
+		// nothing is written in rollback mode if an address is invalid
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].ReturnCode = common.RcReserved
+				results[i].Err = common.ErrorWithCode(common.ErrCliWriteAborted, results[i].Address)
+			}
+		}
+		requests = requests[:0]
+	}
+	if len(requests) == 0 {
+		token.v = results
+		token.flowComplete()
+		return
+	}
+	c.writeRequests(requests, dataItems, options, func(outcome writeOutcome, err error) {
+		if err != nil && outcome.restored == nil {
+			token.setError(err)
+			return
+		}
+		// restored if all requests of the result are restored
+		restored := make(map[int]bool)
+		for i := range requests {
+			result := &results[owners[i]]
+			if err == nil && result.Err == nil && outcome.errs[i] != nil {
+				result.ReturnCode = outcome.codes[i]
+				result.Err = outcome.errs[i]
+			}
+			if r, ok := outcome.restored[i]; ok {
+				prev, seen := restored[owners[i]]
+				restored[owners[i]] = r && (prev || !seen)
+			}
+		}
+		for i, r := range restored {
+			results[i].Restored = r
+			if r && results[i].Err == nil {
+				results[i].Err = common.ErrorWithCode(common.ErrCliWriteRolledBack, results[i].Address)
+			}
+		}
+		if err != nil {
+			// write failed at all, the results are restored best-effort
+			for i := range results {
+				if results[i].Err == nil {
+					results[i].Err = err
+				}
+			}
+		}
+		token.v = results
+		token.flowComplete()
+	})
 }
 
-func (c *client) BaseRead(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, size int) *BaseReadToken {
+func (c *client) BaseRead(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, size int, opts ...CallOption) *BaseReadToken {
 	token := NewToken(TtBaseRead).(*BaseReadToken)
 	item := core.NewStandardRequestItem(area, dbNumber, common.PvtByte, byteAddr, bitAddr, size)
-	c.read([]common.RequestItem{item}).Async(func(v []*core.DataItem, err error) {
+	c.read([]common.RequestItem{item}, opts...).then(func(v []*core.DataItem, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -191,15 +539,15 @@ func (c *client) BaseRead(area common.AreaType, dbNumber int, byteAddr int, bitA
 	return token
 }
 
-func (c *client) BaseWrite(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, data []byte) *SimpleToken {
+func (c *client) BaseWrite(area common.AreaType, dbNumber int, byteAddr int, bitAddr int, data []byte, opts ...CallOption) *SimpleToken {
 	item := core.NewStandardRequestItem(area, dbNumber, common.PvtByte, byteAddr, bitAddr, len(data))
 	dataItem := core.NewReqDataItem(data, item.VariableType.DataVariableType())
-	return c.write([]common.RequestItem{item}, []common.ResponseItem{dataItem})
+	return c.write([]common.RequestItem{item}, []common.ResponseItem{dataItem}, opts...)
 }
 
 func (c *client) HotRestart() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewHotRestart(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewHotRestart(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -211,7 +559,7 @@ func (c *client) HotRestart() *SimpleToken {
 
 func (c *client) ColdRestart() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewColdRestart(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewColdRestart(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -223,7 +571,7 @@ func (c *client) ColdRestart() *SimpleToken {
 
 func (c *client) StopPlc() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewStopPlc(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewStopPlc(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -235,7 +583,7 @@ func (c *client) StopPlc() *SimpleToken {
 
 func (c *client) CopyRamToRom() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewCopyRamToRom(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewCopyRamToRom(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -247,7 +595,7 @@ func (c *client) CopyRamToRom() *SimpleToken {
 
 func (c *client) Compress() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewCompress(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewCompress(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -259,7 +607,7 @@ func (c *client) Compress() *SimpleToken {
 
 func (c *client) InsertFile(bt common.BlockType, blockNumber int) *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewInsert(bt, common.DfsP, blockNumber, c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewInsert(bt, common.DfsP, blockNumber, c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -269,83 +617,91 @@ func (c *client) InsertFile(bt common.BlockType, blockNumber int) *SimpleToken {
 	return token
 }
 
-func (c *client) UploadFile(bt common.BlockType, blockNumber int) *UploadToken {
+func (c *client) UploadFile(bt common.BlockType, blockNumber int, opts ...CallOption) *UploadToken {
 	token := NewToken(TtUpload).(*UploadToken)
-	c.send(core.NewStartUpload(bt, common.DfsA, blockNumber, c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	priority := c.callOptions(append([]CallOption{WithPriority(PrBulk)}, opts...)).priority
+	c.sendPriority(core.NewStartUpload(bt, common.DfsA, blockNumber, c.GeneratePduNumber()), priority).then(func(v *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
 		}
 		parameter := v.GetParameter().(*core.StartUploadAckParameter)
 		res := make([]byte, 0, parameter.BlockLength)
-		ackParameter := core.NewUploadAckParameter()
-		ackParameter.MoreDataFollowing = true
-		var uploadAck *core.PDU
-		for ackParameter.MoreDataFollowing {
-			uploadToken := c.send(core.NewUpload(parameter.Id, c.GeneratePduNumber()))
-			uploadAck, err = uploadToken.Wait()
-			if err != nil {
-				token.setError(err)
-				return
-			}
-			ackParameter = uploadAck.GetParameter().(*core.UploadAckParameter)
-			if ackParameter.ErrorStatus {
-				err = common.ErrorWithCode(common.ErrCliUploadFailed)
-				token.setError(err)
-				return
-			}
-			datum := uploadAck.GetDatum().(*core.UpDownloadDatum)
-			res = append(res, datum.Data...)
-		}
-		endToken := c.send(core.NewEndUpload(parameter.Id, c.GeneratePduNumber()))
-		_, err = endToken.Wait()
-		if err != nil {
-			token.setError(err)
-			return
+		// the chunks are requested one by one, each by the completion of the previous one
+		var upload func()
+		upload = func() {
+			c.sendPriority(core.NewUpload(parameter.Id, c.GeneratePduNumber()), priority).then(func(uploadAck *core.PDU, err error) {
+				if err != nil {
+					token.setError(err)
+					return
+				}
+				ackParameter := uploadAck.GetParameter().(*core.UploadAckParameter)
+				if ackParameter.ErrorStatus {
+					token.setError(common.ErrorWithCode(common.ErrCliUploadFailed))
+					return
+				}
+				datum := uploadAck.GetDatum().(*core.UpDownloadDatum)
+				res = append(res, datum.Data...)
+				if ackParameter.MoreDataFollowing {
+					upload()
+					return
+				}
+				c.sendPriority(core.NewEndUpload(parameter.Id, c.GeneratePduNumber()), priority).then(func(_ *core.PDU, err error) {
+					if err != nil {
+						token.setError(err)
+						return
+					}
+					token.v = res
+					token.flowComplete()
+				})
+			})
 		}
-		token.v = res
-		token.flowComplete()
+		upload()
 	})
 
 	return token
 }
 
-func (c *client) DownloadFile(bytes []byte, bt common.BlockType, bn int, mC7CodeLength int) *SimpleToken {
+func (c *client) DownloadFile(bytes []byte, bt common.BlockType, bn int, mC7CodeLength int, opts ...CallOption) *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
 	total := len(bytes)
-	c.send(core.NewStartDownload(bt, common.DfsP, bn, total, mC7CodeLength, c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	priority := c.callOptions(append([]CallOption{WithPriority(PrBulk)}, opts...)).priority
+	c.sendPriority(core.NewStartDownload(bt, common.DfsP, bn, total, mC7CodeLength, c.GeneratePduNumber()), priority).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
 		}
-		sent := 0
-		for sent < total {
-			moreDataFollowing := total-sent > c.pduLength-32
-			length := int(math.Min(float64(total-sent), float64(c.pduLength-32)))
-			downloadToken := c.send(core.NewDownload(bt, common.DfsP, bn, moreDataFollowing, bytes[sent:sent+length], c.GeneratePduNumber()))
-			_, err = downloadToken.Wait()
-			if err != nil {
-				token.setError(err)
+		// the chunks are sent one by one, each by the completion of the previous one
+		var download func(sent int)
+		download = func(sent int) {
+			if sent >= total {
+				c.sendPriority(core.NewEndDownload(bt, common.DfsP, bn, c.GeneratePduNumber()), priority).then(func(_ *core.PDU, err error) {
+					if err != nil {
+						token.setError(err)
+						return
+					}
+					token.flowComplete()
+				})
 				return
 			}
-			sent += length
-		}
-		endToken := c.send(core.NewEndDownload(bt, common.DfsP, bn, c.GeneratePduNumber()))
-		_, err = endToken.Wait()
-		if err != nil {
-			token.setError(err)
-			return
+			moreDataFollowing := total-sent > c.pduLength-32
+			length := int(math.Min(float64(total-sent), float64(c.pduLength-32)))
+			c.sendPriority(core.NewDownload(bt, common.DfsP, bn, moreDataFollowing, bytes[sent:sent+length], c.GeneratePduNumber()), priority).then(func(_ *core.PDU, err error) {
+				if err != nil {
+					token.setError(err)
+					return
+				}
+				download(sent + length)
+			})
 		}
-		token.flowComplete()
+		download(0)
 	})
 	return token
 }
 
 func (c *client) GetSzlIds() *SzlIdsToken {
 	token := NewToken(TtSzlIds).(*SzlIdsToken)
-	go func() {
-		szlToken := c.ReadSzl(0x0000, 0x0000)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x0000, 0x0000).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -361,15 +717,13 @@ func (c *client) GetSzlIds() *SzlIdsToken {
 		}
 		token.v = res
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) GetCatalog() *CatalogToken {
 	token := NewToken(TtCatalog).(*CatalogToken)
-	go func() {
-		szlToken := c.ReadSzl(0x0011, 0x0000)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x0011, 0x0000).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -389,15 +743,13 @@ func (c *client) GetCatalog() *CatalogToken {
 				datum.Parts[2][27]),
 		}
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) GetPlcStatus() *PlcStatusToken {
 	token := NewToken(TtPlcStatus).(*PlcStatusToken)
-	go func() {
-		szlToken := c.ReadSzl(0x0024, 0x0000)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x0024, 0x0000).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -409,15 +761,13 @@ func (c *client) GetPlcStatus() *PlcStatusToken {
 		}
 		token.v = core.PlcStatus(datum.Parts[0][3])
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) GetUnitInfo() *UnitInfoToken {
 	token := NewToken(TtUnitInfo).(*UnitInfoToken)
-	go func() {
-		szlToken := c.ReadSzl(0x001C, 0x0000)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x001C, 0x0000).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -435,15 +785,13 @@ func (c *client) GetUnitInfo() *UnitInfoToken {
 			ModuleTypeName: strings.TrimSpace(string(datum.Parts[5][2:26])),
 		}
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) GetCommunicationInfo() *CommunicationInfoToken {
 	token := NewToken(TtCommunicationInfo).(*CommunicationInfoToken)
-	go func() {
-		szlToken := c.ReadSzl(0x0131, 0x0000)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x0131, 0x0000).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -460,15 +808,13 @@ func (c *client) GetCommunicationInfo() *CommunicationInfoToken {
 			MaxBusRate:     int(binary.BigEndian.Uint16(datum.Parts[0][10:])),
 		}
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) GetProtectionInfo() *ProtectionInfoToken {
 	token := NewToken(TtProtectionInfo).(*ProtectionInfoToken)
-	go func() {
-		szlToken := c.ReadSzl(0x0232, 0x0004)
-		pdu, err := szlToken.Wait()
+	c.ReadSzl(0x0232, 0x0004).then(func(pdu *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -486,13 +832,13 @@ func (c *client) GetProtectionInfo() *ProtectionInfoToken {
 			StartupSwitch:   common.StartupSwitch(binary.BigEndian.Uint16(datum.Parts[0][10:])),
 		}
 		token.flowComplete()
-	}()
+	})
 	return token
 }
 
 func (c *client) ReadSzl(szlId uint16, szlIndex uint16) *PduToken {
 	t := NewToken(TtPdu).(*PduToken)
-	c.send(core.NewReadSzl(szlId, szlIndex, c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	c.send(core.NewReadSzl(szlId, szlIndex, c.GeneratePduNumber())).then(func(v *core.PDU, err error) {
 		if err != nil {
 			t.setError(err)
 			return
@@ -509,7 +855,7 @@ func (c *client) ReadSzl(szlId uint16, szlIndex uint16) *PduToken {
 
 func (c *client) BlockList() *BlockListToken {
 	token := NewToken(TtBlockList).(*BlockListToken)
-	c.send(core.NewBlockList(c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	c.send(core.NewBlockList(c.GeneratePduNumber())).then(func(v *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -522,7 +868,7 @@ func (c *client) BlockList() *BlockListToken {
 
 func (c *client) BlockListType(bt common.BlockType) *BlockListTypeToken {
 	token := NewToken(TtBlockListType).(*BlockListTypeToken)
-	c.send(core.NewBlockListType(bt, c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	c.send(core.NewBlockListType(bt, c.GeneratePduNumber())).then(func(v *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -535,7 +881,7 @@ func (c *client) BlockListType(bt common.BlockType) *BlockListTypeToken {
 
 func (c *client) BlockInfo(bt common.BlockType, bn int) *BlockInfoToken {
 	token := NewToken(TtBlockInfo).(*BlockInfoToken)
-	c.send(core.NewBlockInfo(bt, common.DfsA, bn, c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	c.send(core.NewBlockInfo(bt, common.DfsA, bn, c.GeneratePduNumber())).then(func(v *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -558,8 +904,8 @@ func (c *client) BlockInfo(bt common.BlockType, bn int) *BlockInfoToken {
 			Version:          int(datum.Version),
 			CodeDate:         siemensTimestamp(codeDate),
 			InterfaceDate:    siemensTimestamp(interfaceDate),
-			Author:           strings.TrimSpace(string(datum.Auth)),
-			Family:           strings.TrimSpace(string(datum.Family)),
+			Author:           c.decodeName(datum.Auth),
+			Family:           c.decodeName(datum.Family),
 			Header:           strings.TrimSpace(string(datum.Header)),
 		}
 		token.flowComplete()
@@ -567,9 +913,18 @@ func (c *client) BlockInfo(bt common.BlockType, bn int) *BlockInfoToken {
 	return token
 }
 
-func (c *client) DBFill(dbNumber int, fillByte byte) *SimpleToken {
+// decodeName decode block info name in the charset of client
+func (c *client) decodeName(bs []byte) string {
+	s, err := c.charset.Decode(bytes.TrimRight(bs, "\x00"))
+	if err != nil {
+		s = string(bs)
+	}
+	return strings.TrimSpace(s)
+}
+
+func (c *client) DBFill(dbNumber int, fillByte byte, opts ...CallOption) *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.BlockInfo(common.DtDb, dbNumber).Async(func(v core.BlockInfo, err error) {
+	c.BlockInfo(common.DtDb, dbNumber).then(func(v core.BlockInfo, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -578,25 +933,23 @@ func (c *client) DBFill(dbNumber int, fillByte byte) *SimpleToken {
 		for i := 0; i < v.MC7CodeLength; i++ {
 			data[i] = fillByte
 		}
-		c.BaseWrite(common.AtDataBlocks, dbNumber, 0, 0, data).Async(func(err error) {
-			if err != nil {
-				token.setError(err)
-				return
-			}
-			token.flowComplete()
-		})
+		item := core.NewStandardRequestItem(common.AtDataBlocks, dbNumber, common.PvtByte, 0, 0, len(data))
+		dataItem := core.NewReqDataItem(data, item.VariableType.DataVariableType())
+		requests := []common.RequestItem{item}
+		options := c.callOptions(append([]CallOption{WithPriority(PrBulk)}, opts...))
+		c.writeRequests(requests, []common.ResponseItem{dataItem}, options, writeCompleter(token, requests))
 	})
 	return token
 }
 
-func (c *client) DBGet(dbNumber int) *BaseReadToken {
+func (c *client) DBGet(dbNumber int, opts ...CallOption) *BaseReadToken {
 	token := NewToken(TtBaseRead).(*BaseReadToken)
-	c.BlockInfo(common.DtDb, dbNumber).Async(func(v core.BlockInfo, err error) {
+	c.BlockInfo(common.DtDb, dbNumber).then(func(v core.BlockInfo, err error) {
 		if err != nil {
 			token.setError(err)
 			return
 		}
-		c.BaseRead(common.AtDataBlocks, dbNumber, 0, 0, v.MC7CodeLength).Async(func(v []byte, err error) {
+		c.BaseRead(common.AtDataBlocks, dbNumber, 0, 0, v.MC7CodeLength, append([]CallOption{WithPriority(PrBulk)}, opts...)...).then(func(v []byte, err error) {
 			if err != nil {
 				token.setError(err)
 				return
@@ -610,7 +963,7 @@ func (c *client) DBGet(dbNumber int) *BaseReadToken {
 
 func (c *client) ClockRead() *ClockReadToken {
 	token := NewToken(TtClockRead).(*ClockReadToken)
-	c.send(core.NewClockRead(c.GeneratePduNumber())).Async(func(v *core.PDU, err error) {
+	c.send(core.NewClockRead(c.GeneratePduNumber())).then(func(v *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -637,7 +990,7 @@ func (c *client) ClockRead() *ClockReadToken {
 
 func (c *client) ClockSet(t time.Time) *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewClockSet(t, c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewClockSet(t, c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -653,7 +1006,7 @@ func (c *client) SetPassword(pwd string) *SimpleToken {
 		token.setError(common.ErrorWithCode(common.ErrPasswordLengthInvalid, 8))
 		return token
 	}
-	c.send(core.NewSetPassword(pwd, c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewSetPassword(pwd, c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -665,7 +1018,7 @@ func (c *client) SetPassword(pwd string) *SimpleToken {
 
 func (c *client) ClearPassword() *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
-	c.send(core.NewClearPassword(c.GeneratePduNumber())).Async(func(_ *core.PDU, err error) {
+	c.send(core.NewClearPassword(c.GeneratePduNumber())).then(func(_ *core.PDU, err error) {
 		if err != nil {
 			token.setError(err)
 			return
@@ -676,24 +1029,10 @@ func (c *client) ClearPassword() *SimpleToken {
 }
 
 func (c *client) autoConnect(endpoint string) (conn net.Conn, err error) {
-	conn, err = net.DialTimeout("tcp", endpoint, c.timeout)
-	backoff := c.retryInterval
-
-	for retries := 1; c.maxRetries == -1 || retries <= c.maxRetries; retries++ {
-		conn, err = net.Dial("tcp", endpoint)
-		if err == nil {
-			return conn, nil
-		}
-
-		c.logger.Debugf("retrying in %v, failed to connect to %s: %v", backoff, endpoint, err)
-		if backoff < c.maxRetryBackoff {
-			backoff *= 2
-		}
-		if retries != c.maxRetries {
-			time.Sleep(backoff)
-		}
+	conn, attempts, err := c.dial(endpoint, c.connectBackoff)
+	if err != nil {
+		err = common.ErrorWithCode(common.ErrTcpConnectWithAttempts, endpoint, attempts, err)
 	}
-	err = common.ErrorWithCode(common.ErrTcpConnectWithAttempts, endpoint, c.maxRetries)
 	return
 }
 
@@ -733,9 +1072,7 @@ func checkReqAck(req *core.PDU, ack *core.PDU) (err error) {
 	}
 
 	if ackHeader, ok := ack.GetHeader().(*core.AckHeader); ok && ackHeader.ErrorClass != 0x00 {
-		err = common.ErrorWithCode(common.ErrCliResponseExceptional,
-			common.ErrorClassDescOrDefault(ackHeader.ErrorClass, "UnKnown"),
-			common.ErrorCodeDescOrDefault(ackHeader.ErrorCode, "UnKnown"))
+		err = common.S7Error(ackHeader.ErrorClass, ackHeader.ErrorCode)
 		return
 	}
 
@@ -745,9 +1082,7 @@ func checkReqAck(req *core.PDU, ack *core.PDU) (err error) {
 	}
 
 	if parameter, ok := ack.GetParameter().(*core.UserdataAckParameter); ok && parameter.ErrorClass != 0x00 {
-		err = common.ErrorWithCode(common.ErrCliResponseExceptional,
-			common.ErrorClassDescOrDefault(parameter.ErrorClass, "UnKnown"),
-			common.ErrorCodeDescOrDefault(parameter.ErrorCode, "UnKnown"))
+		err = common.S7Error(parameter.ErrorClass, parameter.ErrorCode)
 		return
 	}
 
@@ -758,23 +1093,119 @@ func checkReqAck(req *core.PDU, ack *core.PDU) (err error) {
 	if datum, ok := ack.GetDatum().(*core.ReadWriteDatum); !ok {
 		return
 	} else if readWriteParameter, ok := req.GetParameter().(*core.ReadWriteParameter); ok {
+		// return codes of items are checked by the caller
 		if len(datum.ReturnItems) != int(readWriteParameter.ItemCount) {
 			err = common.ErrorWithCode(common.ErrCliResponseLengthMismatch)
 			return
 		}
-		for i := 0; i < len(datum.ReturnItems); i++ {
-			item := datum.ReturnItems[i]
-			if item.GetReturnCode() != common.RcSuccess {
-				err = common.ErrorWithCode(common.ErrCliResponseExceptional,
-					"UnKnown", common.ReturnCodeDescOrDefault(item.GetReturnCode(), "UnKnown"))
+	}
+	return
+}
+
+// checkReturnCodes error of the first item not succeeded
+func checkReturnCodes(codes []common.ReturnCode) error {
+	for _, code := range codes {
+		if code != common.RcSuccess {
+			return common.ReturnCodeError{ReturnCode: code}
+		}
+	}
+	return nil
+}
+
+// returnCodeOf return code of the error of an item, RcReserved if the item was not answered by plc
+func returnCodeOf(err error) common.ReturnCode {
+	var rce common.ReturnCodeError
+	if errors.As(err, &rce) {
+		return rce.ReturnCode
+	}
+	return common.RcReserved
+}
+
+func (c *client) read(requests []common.RequestItem, opts ...CallOption) *ReadToken {
+	token := NewToken(TtRead).(*ReadToken)
+	c.readItems(requests, c.callOptions(opts).priority).then(func(v []*core.DataItem, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		for i, item := range v {
+			if item.ReturnCode != common.RcSuccess {
+				token.setError(common.ReturnCodeError{ReturnCode: item.ReturnCode, Address: requests[i].(*core.StandardRequestItem).String()})
 				return
 			}
 		}
+		token.v = v
+		token.flowComplete()
+	})
+	return token
+}
+
+// readItems read the requests, failed items do not fail the others
+// neighbouring items are read as one range, see coalesceReads
+func (c *client) readItems(requests []common.RequestItem, priority Priority) *ReadToken {
+	if c.readCoalesceGap < 0 || len(requests) < 2 {
+		return c.readSplit(requests, priority)
 	}
-	return
+	ranges, slices := coalesceReads(requests, c.readCoalesceGap, c.pduLength-18)
+	if len(ranges) == len(requests) {
+		return c.readSplit(requests, priority)
+	}
+	token := NewToken(TtRead).(*ReadToken)
+	rangeItems := make([]common.RequestItem, 0, len(ranges))
+	for _, r := range ranges {
+		rangeItems = append(rangeItems, r.item)
+	}
+	c.readSplit(rangeItems, priority).then(func(v []*core.DataItem, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		result := make([]*core.DataItem, len(requests))
+		retries := make([]int, 0)
+		for i, r := range ranges {
+			if v[i].ReturnCode != common.RcSuccess && len(r.members) > 1 {
+				// a failed range may cover a bad address, read the members alone
+				retries = append(retries, r.members...)
+				continue
+			}
+			for _, member := range r.members {
+				item := requests[member].(*core.StandardRequestItem)
+				dataItem := core.NewAckDataItem(nil, item.VariableType.DataVariableType())
+				dataItem.ReturnCode = v[i].ReturnCode
+				if v[i].ReturnCode == common.RcSuccess {
+					dataItem.Data = slices[member].data(v[i].Data)
+					dataItem.Count = uint16(len(dataItem.Data))
+				}
+				result[member] = dataItem
+			}
+		}
+		if len(retries) > 0 {
+			retryItems := make([]common.RequestItem, 0, len(retries))
+			for _, member := range retries {
+				retryItems = append(retryItems, requests[member])
+			}
+			c.readSplit(retryItems, priority).then(func(retried []*core.DataItem, err error) {
+				if err != nil {
+					token.setError(err)
+					return
+				}
+				for i, member := range retries {
+					result[member] = retried[i]
+				}
+				token.v = result
+				token.flowComplete()
+			})
+			return
+		}
+		token.v = result
+		token.flowComplete()
+	})
+	return token
 }
 
-func (c *client) read(requests []common.RequestItem) *ReadToken {
+// readSplit read the requests split by pdu length, failed items do not fail the others
+// the return code of each request is set to its data item, the first failure of split parts wins
+func (c *client) readSplit(requests []common.RequestItem, priority Priority) *ReadToken {
 	token := NewToken(TtRead).(*ReadToken)
 
 	if len(requests) == 0 {
@@ -782,28 +1213,35 @@ func (c *client) read(requests []common.RequestItem) *ReadToken {
 		return token
 	}
 
-	go func() {
-		rawNumbers := make([]uint16, 0, len(requests))
-		result := make([]*core.DataItem, 0, len(requests))
-		for _, request := range requests {
-			request := request.(*core.StandardRequestItem)
-			rawNumbers = append(rawNumbers, request.Count)
-			result = append(result, core.NewReqDataItem(make([]byte, int(request.VariableType.Size()*request.Count)), request.VariableType.DataVariableType()))
-		}
+	rawNumbers := make([]uint16, 0, len(requests))
+	result := make([]*core.DataItem, 0, len(requests))
+	for _, request := range requests {
+		request := request.(*core.StandardRequestItem)
+		rawNumbers = append(rawNumbers, request.Count)
+		result = append(result, core.NewAckDataItem(make([]byte, int(request.VariableType.Size()*request.Count)), request.VariableType.DataVariableType()))
+	}
 
-		groups := util.ReadRecombination(rawNumbers, c.pduLength-14, 5, 12)
-		for _, group := range groups {
-			newRequestItems := make([]common.RequestItem, 0)
-			for i := 0; i < len(group.Items); i++ {
-				item := group.Items[i]
-				requestItem := *(requests[item.Index].(*core.StandardRequestItem))
-				requestItem.Count = uint16(item.RipeSize)
-				requestItem.ByteAddress += item.SplitOffset
-				newRequestItems = append(newRequestItems, &requestItem)
-			}
-			request := core.NewReadRequest(newRequestItems, c.GeneratePduNumber())
-			pduToken := c.send(request)
-			ack, err := pduToken.Wait()
+	groups := util.ReadRecombination(rawNumbers, c.pduLength-14, 5, 12)
+	c.metrics.Splits(metrics.OpRead, len(groups))
+	// the groups are sent one by one, each by the completion of the previous one
+	var send func(index int)
+	send = func(index int) {
+		if index == len(groups) {
+			token.v = result
+			token.flowComplete()
+			return
+		}
+		group := groups[index]
+		newRequestItems := make([]common.RequestItem, 0)
+		for i := 0; i < len(group.Items); i++ {
+			item := group.Items[i]
+			requestItem := *(requests[item.Index].(*core.StandardRequestItem))
+			requestItem.Count = uint16(item.RipeSize)
+			requestItem.ByteAddress += item.SplitOffset
+			newRequestItems = append(newRequestItems, &requestItem)
+		}
+		request := core.NewReadRequest(newRequestItems, c.GeneratePduNumber())
+		c.sendPriority(request, priority).then(func(ack *core.PDU, err error) {
 			if err != nil {
 				token.setError(err)
 				return
@@ -811,18 +1249,203 @@ func (c *client) read(requests []common.RequestItem) *ReadToken {
 			datum := ack.GetDatum().(*core.ReadWriteDatum)
 			for i := 0; i < len(group.Items); i++ {
 				item := group.Items[i]
-				copy(result[item.Index].Data[item.SplitOffset:], datum.ReturnItems[i].(*core.DataItem).Data)
+				dataItem := datum.ReturnItems[i].(*core.DataItem)
+				if dataItem.ReturnCode != common.RcSuccess {
+					if result[item.Index].ReturnCode == common.RcSuccess {
+						result[item.Index].ReturnCode = dataItem.ReturnCode
+					}
+					continue
+				}
+				copy(result[item.Index].Data[item.SplitOffset:], dataItem.Data)
 			}
-		}
-		token.v = result
-		token.flowComplete()
-	}()
+			send(index + 1)
+		})
+	}
+	send(0)
 
 	return token
 }
 
-func (c *client) write(requests []common.RequestItem, dataItems []common.ResponseItem) *SimpleToken {
+func (c *client) write(requests []common.RequestItem, dataItems []common.ResponseItem, opts ...CallOption) *SimpleToken {
 	token := NewToken(TtSimple).(*SimpleToken)
+	c.writeRequests(requests, dataItems, c.callOptions(opts), writeCompleter(token, requests))
+	return token
+}
+
+// writeCompleter completes the token of a write of the requests by its outcome
+func writeCompleter(token *SimpleToken, requests []common.RequestItem) func(outcome writeOutcome, err error) {
+	return func(outcome writeOutcome, err error) {
+		if err == nil {
+			err = errors.Join(outcome.errs...)
+		}
+		if err != nil && outcome.restored != nil {
+			rollbackErr := common.RollbackError{Cause: err}
+			for i, request := range requests {
+				if restored, ok := outcome.restored[i]; ok && restored {
+					rollbackErr.Restored = append(rollbackErr.Restored, request.(*core.StandardRequestItem).String())
+				} else if ok {
+					rollbackErr.NotRestored = append(rollbackErr.NotRestored, request.(*core.StandardRequestItem).String())
+				}
+			}
+			err = rollbackErr
+		}
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		token.flowComplete()
+	}
+}
+
+// writeOutcome outcome of each request of a write
+type writeOutcome struct {
+	codes []common.ReturnCode
+	// errs error of each request: return code, aborted or verify error
+	errs []error
+	// restored requests written back with the original data after a failed write in rollback mode,
+	// true if restored, false if restore failed, requests not written are absent. nil without rollback
+	restored map[int]bool
+}
+
+// writeRequests write the requests with the options, done is called with the outcome
+// the error is set if the write could not be done at all, e.g. connection lost
+func (c *client) writeRequests(requests []common.RequestItem, dataItems []common.ResponseItem, options callOptions,
+	done func(outcome writeOutcome, err error)) {
+	if !options.rollback {
+		c.writeJournaled(requests, dataItems, options, nil, done)
+		return
+	}
+	// journal of the original data, nothing is written if it can not be read
+	c.read(requests, WithPriority(options.priority)).then(func(originals []*core.DataItem, err error) {
+		if err != nil {
+			done(writeOutcome{}, common.ErrorWithCode(common.ErrCliRollbackJournal, err))
+			return
+		}
+		c.writeJournaled(requests, dataItems, options, originals, done)
+	})
+}
+
+// writeJournaled write the requests, verified if required, the originals are restored after a failure in rollback mode
+func (c *client) writeJournaled(requests []common.RequestItem, dataItems []common.ResponseItem, options callOptions,
+	originals []*core.DataItem, done func(outcome writeOutcome, err error)) {
+	c.writeItems(requests, dataItems, options.writeMode, options.priority).then(func(codes []common.ReturnCode, err error) {
+		outcome := writeOutcome{codes: codes, errs: make([]error, len(requests))}
+		if err == nil {
+			for i, code := range outcome.codes {
+				switch code {
+				case common.RcSuccess:
+				case common.RcReserved:
+					outcome.errs[i] = common.ErrorWithCode(common.ErrCliWriteAborted, requests[i].(*core.StandardRequestItem).String())
+				default:
+					outcome.errs[i] = common.ReturnCodeError{ReturnCode: code, Address: requests[i].(*core.StandardRequestItem).String()}
+				}
+			}
+		}
+		finish := func(err error) {
+			if options.rollback && (err != nil || errors.Join(outcome.errs...) != nil) {
+				c.restore(requests, originals, outcome.codes, options.priority, func(restored map[int]bool) {
+					outcome.restored = restored
+					done(outcome, err)
+				})
+				return
+			}
+			done(outcome, err)
+		}
+		if err != nil || !options.verify {
+			finish(err)
+			return
+		}
+		c.verify(requests, dataItems, outcome.codes, options.priority, func(verifyErrs []error, err error) {
+			if err == nil {
+				for i, verifyErr := range verifyErrs {
+					if outcome.errs[i] == nil {
+						outcome.errs[i] = verifyErr
+					}
+				}
+			}
+			finish(err)
+		})
+	})
+}
+
+// restore write back the original data of the requests possibly written, best-effort, done is called with the restored
+// without codes, e.g. connection lost during the write, all requests are restored
+func (c *client) restore(requests []common.RequestItem, originals []*core.DataItem, codes []common.ReturnCode, priority Priority,
+	done func(restored map[int]bool)) {
+	restored := make(map[int]bool)
+	restoreRequests := make([]common.RequestItem, 0, len(requests))
+	restoreDataItems := make([]common.ResponseItem, 0, len(requests))
+	indexes := make([]int, 0, len(requests))
+	for i, request := range requests {
+		if codes != nil && codes[i] == common.RcReserved {
+			continue
+		}
+		restoreRequests = append(restoreRequests, request)
+		restoreDataItems = append(restoreDataItems, core.NewReqDataItem(originals[i].Data, request.(*core.StandardRequestItem).VariableType.DataVariableType()))
+		indexes = append(indexes, i)
+		restored[i] = false
+	}
+	if len(restoreRequests) == 0 {
+		done(restored)
+		return
+	}
+	c.writeItems(restoreRequests, restoreDataItems, WmFastest, priority).then(func(restoreCodes []common.ReturnCode, err error) {
+		if err != nil {
+			c.logger.Errorf("S7 client failed to restore original data after write failure: %s", err)
+			done(restored)
+			return
+		}
+		for i, code := range restoreCodes {
+			restored[indexes[i]] = code == common.RcSuccess
+		}
+		done(restored)
+	})
+}
+
+// verify read back the requests written with success and compare with the written data
+// done is called with the verify error of each request, nil if equal or not written
+func (c *client) verify(requests []common.RequestItem, dataItems []common.ResponseItem, codes []common.ReturnCode, priority Priority,
+	done func(errs []error, err error)) {
+	errs := make([]error, len(requests))
+	written := make([]common.RequestItem, 0, len(requests))
+	indexes := make([]int, 0, len(requests))
+	for i, request := range requests {
+		if codes[i] == common.RcSuccess {
+			written = append(written, request)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(written) == 0 {
+		done(errs, nil)
+		return
+	}
+	c.readItems(written, priority).then(func(res []*core.DataItem, err error) {
+		if err != nil {
+			done(nil, err)
+			return
+		}
+		for i, dataItem := range res {
+			index := indexes[i]
+			expected := dataItems[index].(*core.DataItem).Data
+			if dataItem.ReturnCode != common.RcSuccess {
+				errs[index] = common.ReturnCodeError{ReturnCode: dataItem.ReturnCode, Address: requests[index].(*core.StandardRequestItem).String()}
+				continue
+			}
+			if !bytes.Equal(expected, dataItem.Data) {
+				// written bytes of the item, e.g. DB1.B0[4] for DB1.REAL0
+				item := *written[i].(*core.StandardRequestItem)
+				item.Array = item.VariableType == common.PvtByte && item.Count > 1
+				errs[index] = common.VerifyError{Address: item.String(), Expected: expected, Actual: dataItem.Data}
+			}
+		}
+		done(errs, nil)
+	})
+}
+
+// writeItems write the requests merged by coalesceWrites, failed items do not fail the others in fastest mode
+// return code of each request, RcReserved for the requests not written after a failure in ordered mode
+func (c *client) writeItems(requests []common.RequestItem, dataItems []common.ResponseItem, mode WriteMode, priority Priority) *WriteToken {
+	token := NewToken(TtWrite).(*WriteToken)
 	if len(requests) == 0 || len(dataItems) == 0 {
 		token.setError(common.ErrorWithCode(common.ErrCliRequestDataEmpty))
 		return token
@@ -831,48 +1454,164 @@ func (c *client) write(requests []common.RequestItem, dataItems []common.Respons
 		token.setError(common.ErrorWithCode(common.ErrCliRequestDataDifferent))
 		return token
 	}
-
-	go func() {
-		rawNumbers := make([]uint16, 0, len(requests))
-		for _, request := range requests {
-			request := request.(*core.StandardRequestItem)
-			rawNumbers = append(rawNumbers, request.Count)
+	mergedRequests, mergedDataItems, owners := coalesceWrites(requests, dataItems, mode == WmOrdered)
+	c.writeSplit(mergedRequests, mergedDataItems, mode == WmOrdered, priority).then(func(v []common.ReturnCode, err error) {
+		if err != nil {
+			token.setError(err)
+			return
 		}
+		codes := make([]common.ReturnCode, len(requests))
+		for i, code := range v {
+			for _, owner := range owners[i] {
+				codes[owner] = code
+			}
+		}
+		token.v = codes
+		token.flowComplete()
+	})
+	return token
+}
 
-		groups := util.WriteRecombination(rawNumbers, c.pduLength-12, 17)
-		for _, group := range groups {
-			items := group.Items
-			newRequestItems := make([]common.RequestItem, 0)
-			newDataItems := make([]common.ResponseItem, 0)
-			for i := 0; i < len(items); i++ {
-				item := items[i]
-				requestItem := *(requests[item.Index].(*core.StandardRequestItem))
-				requestItem.Count = uint16(item.RipeSize)
-				requestItem.ByteAddress += item.SplitOffset
-				newRequestItems = append(newRequestItems, &requestItem)
-
-				dataItem := *(dataItems[item.Index].(*core.DataItem))
-				dataItem.Count = uint16(item.RipeSize)
-				dataItem.Data = dataItem.Data[item.SplitOffset : item.SplitOffset+item.RipeSize]
-				newDataItems = append(newDataItems, &dataItem)
+// writeSplit write the requests split by pdu length, the pdus are sent one by one
+// return code of each request, the first failure of split parts wins
+// if stopOnFailure, the pdus after a failed item are not sent and the requests not completely written are RcReserved
+func (c *client) writeSplit(requests []common.RequestItem, dataItems []common.ResponseItem, stopOnFailure bool, priority Priority) *WriteToken {
+	token := NewToken(TtWrite).(*WriteToken)
+	rawNumbers := make([]uint16, 0, len(requests))
+	for _, request := range requests {
+		request := request.(*core.StandardRequestItem)
+		rawNumbers = append(rawNumbers, request.Count)
+	}
+
+	codes := make([]common.ReturnCode, len(requests))
+	for i := range codes {
+		codes[i] = common.RcSuccess
+	}
+	written := make([]bool, len(requests))
+	groups := util.WriteRecombination(rawNumbers, c.pduLength-12, 17)
+	c.metrics.Splits(metrics.OpWrite, len(groups))
+	// the groups are sent one by one, each by the completion of the previous one
+	var send func(index int)
+	send = func(index int) {
+		if index == len(groups) {
+			token.v = codes
+			token.flowComplete()
+			return
+		}
+		items := groups[index].Items
+		newRequestItems := make([]common.RequestItem, 0)
+		newDataItems := make([]common.ResponseItem, 0)
+		for i := 0; i < len(items); i++ {
+			item := items[i]
+			requestItem := *(requests[item.Index].(*core.StandardRequestItem))
+			requestItem.Count = uint16(item.RipeSize)
+			requestItem.ByteAddress += item.SplitOffset
+			newRequestItems = append(newRequestItems, &requestItem)
+
+			// timers and counters are counted by number, the data is the value of each
+			size := 1
+			if requestItem.VariableType.DataVariableType() == common.DvtOctetString {
+				size = int(requestItem.VariableType.Size())
 			}
+			dataItem := *(dataItems[item.Index].(*core.DataItem))
+			dataItem.Data = dataItem.Data[item.SplitOffset*size : (item.SplitOffset+item.RipeSize)*size]
+			dataItem.Count = uint16(len(dataItem.Data))
+			newDataItems = append(newDataItems, &dataItem)
+		}
 
-			request := core.NewWriteRequest(newRequestItems, newDataItems, c.GeneratePduNumber())
-			pduToken := c.send(request)
-			_, err := pduToken.Wait()
+		request := core.NewWriteRequest(newRequestItems, newDataItems, c.GeneratePduNumber())
+		c.sendPriority(request, priority).then(func(ack *core.PDU, err error) {
 			if err != nil {
 				token.setError(err)
 				return
 			}
-		}
-		token.flowComplete()
-	}()
+			datum := ack.GetDatum().(*core.ReadWriteDatum)
+			failed := false
+			for i := 0; i < len(items); i++ {
+				code := datum.ReturnItems[i].GetReturnCode()
+				if code != common.RcSuccess && codes[items[i].Index] == common.RcSuccess {
+					codes[items[i].Index] = code
+				}
+				failed = failed || code != common.RcSuccess
+				written[items[i].Index] = items[i].SplitOffset+items[i].RipeSize == items[i].RawSize
+			}
+			if failed && stopOnFailure {
+				for i := range codes {
+					if !written[i] && codes[i] == common.RcSuccess {
+						codes[i] = common.RcReserved
+					}
+				}
+				token.v = codes
+				token.flowComplete()
+				return
+			}
+			send(index + 1)
+		})
+	}
+	send(0)
 	return token
 }
 
 func (c *client) send(request *core.PDU) *PduToken {
+	return c.sendPriority(request, PrInteractive)
+}
+
+// operationOf operation of the request for metrics
+func operationOf(request *core.PDU) string {
+	switch request.GetCOTP().GetPduType() {
+	case common.PtConnectRequest:
+		return metrics.OpConnect
+	case common.PtDisconnectRequest:
+		return metrics.OpDisconnect
+	}
+	switch parameter := request.GetParameter().(type) {
+	case *core.SetupComParameter:
+		return metrics.OpSetup
+	case *core.ReadWriteParameter:
+		if parameter.FunctionCode == common.FcRead {
+			return metrics.OpRead
+		}
+		return metrics.OpWrite
+	case *core.StartUploadParameter, *core.UploadParameter, *core.EndUploadParameter:
+		return metrics.OpUpload
+	case *core.StartDownloadParameter, *core.DownloadParameter, *core.EndDownloadParameter:
+		return metrics.OpDownload
+	case *core.PlcControlParameter, *core.PlcStopParameter:
+		return metrics.OpControl
+	case *core.UserdataParameter:
+		return metrics.OpUserdata
+	default:
+		return metrics.OpOther
+	}
+}
+
+// sendPriority send the request through the interceptors
+func (c *client) sendPriority(request *core.PDU, priority Priority) *PduToken {
+	if c.sendFunc == nil {
+		return c.transmitRetry(request, priority)
+	}
+	p := NewToken(TtPdu).(*PduToken)
+	// interceptors wait for the response, so they run on a goroutine of their own instead of the workers
+	go func() {
+		ack, err := c.sendFunc(request, priority)
+		p.v, p.err = ack, err
+		p.flowComplete()
+	}()
+	return p
+}
+
+// transmitWait transmit the request and wait for the response, innermost send of the interceptors
+func (c *client) transmitWait(request *core.PDU, priority Priority) (*core.PDU, error) {
+	return c.transmitRetry(request, priority).Wait()
+}
+
+// transmit send the request when the scheduler starts it by the priority,
+// the request is written and the response completed by the workers, no goroutine waits for the response
+func (c *client) transmit(request *core.PDU, priority Priority) *PduToken {
 	p := NewToken(TtPdu).(*PduToken)
-	if c.GetConn() == nil {
+	conn := c.GetConn()
+	if conn == nil {
+		c.metrics.Reject()
 		p.setError(common.ErrorWithCode(common.ErrCliConnectionNil, c.host, c.port))
 		return p
 	}
@@ -882,69 +1621,119 @@ func (c *client) send(request *core.PDU) *PduToken {
 		((status == connecting || status == reconnecting) && request.GetCOTP().GetPduType() == common.PtConnectRequest)) {
 		_, ok := request.GetParameter().(*core.SetupComParameter)
 		if (status != connecting && status != reconnecting) && !ok {
+			c.metrics.Reject()
 			p.setError(common.ErrorWithCode(common.ErrCliConnectionInactive, c.host, c.port))
 			return p
 		}
 	}
 
-	go func() {
-		var (
-			ctx RequestContext
-			err error
-		)
-		switch request.GetCOTP().GetPduType() {
-		case common.PtDisconnectRequest:
-			ctx = &ConnectRequestContext{
-				Request:  request,
-				Response: make(chan *core.PDU),
-				Error:    make(chan error),
-			}
-			err = c.tcpClient.handleDisconnectRequestContext(ctx)
-			if err != nil {
-				p.setError(err)
-				return
-			}
-		case common.PtConnectRequest:
-			ctx = &ConnectRequestContext{
-				Request:  request,
-				Response: make(chan *core.PDU),
-				Error:    make(chan error),
-			}
-			err = c.tcpClient.handleConnectRequestContext(ctx)
-			if err != nil {
-				p.setError(err)
-				return
+	switch request.GetCOTP().GetPduType() {
+	case common.PtConnectRequest, common.PtDisconnectRequest:
+		c.pool.submit(func() { c.exchange(conn, request, p, false) })
+	default:
+		c.scheduler.schedule(priority, func() { c.start(conn, request, p) }, func(err error) {
+			c.metrics.Reject()
+			p.setError(err)
+		})
+	}
+	return p
+}
+
+// start write the request started by the scheduler after the delay of the limiter
+func (c *client) start(conn net.Conn, request *core.PDU, p *PduToken) {
+	exchange := func() { c.exchange(conn, request, p, true) }
+	if c.limiter != nil {
+		if delay := c.limiter.reserve(request.Len()); delay > 0 {
+			time.AfterFunc(delay, func() { c.pool.submit(exchange) })
+			return
+		}
+	}
+	c.pool.submit(exchange)
+}
+
+// exchange register the context of the request and write it to conn, the token is completed by a worker
+// on the response or timeout, scheduled requests release the slot of the scheduler when completed.
+// The request fails if conn was closed while it waited in the scheduler, the limiter or the workers
+func (c *client) exchange(conn net.Conn, request *core.PDU, p *PduToken, scheduled bool) {
+	operation := operationOf(request)
+	start := time.Now()
+	var once sync.Once
+	complete := func(ack *core.PDU, err error, sent bool) {
+		once.Do(func() {
+			if ack != nil {
+				c.lastActivity.Store(time.Now().UnixNano())
 			}
-		default:
-			ctx = &StandardRequestContext{
-				RequestId: request.GetHeader().GetPduReference(),
-				Request:   request,
-				Response:  make(chan *core.PDU),
-				Error:     make(chan error),
+			if scheduled {
+				if sent && c.limiter != nil {
+					// a timed out request counts with the timeout as latency
+					size := 0
+					if ack != nil {
+						size = ack.Len()
+					}
+					c.limiter.done(size, time.Since(start), resourceExhausted(ack))
+				}
+				c.scheduler.release()
 			}
-			err = c.tcpClient.handleRequestContext(ctx)
-			if err != nil {
-				p.setError(err)
-				return
+			if err == nil {
+				err = checkReqAck(request, ack)
 			}
+			c.metrics.Operation(operation, time.Since(start), err)
+			p.v, p.err = ack, err
+			p.flowComplete()
+		})
+	}
+	// the response is completed off the event loop
+	callback := func(ack *core.PDU, err error) {
+		c.pool.submit(func() { complete(ack, err, true) })
+	}
+
+	// reject fail the request which is not written
+	reject := func(err error) {
+		c.metrics.Reject()
+		if scheduled {
+			c.scheduler.release()
 		}
-		pdu := ctx.GetRequest().ToBytes()
-		_, err = c.conn.Write(pdu)
-		if err != nil {
-			p.setError(err)
-			return
-		}
-		c.logger.Debugf("S7 client sending: % x", pdu)
-		ack, err := ctx.GetResponse()
-		if err != nil {
-			p.setError(err)
-			return
+		p.setError(err)
+	}
+	if c.GetConn() != conn {
+		// closed while waiting, a reconnect opens another connection
+		reject(common.ErrorWithCode(common.ErrCliConnectionNil, c.host, c.port))
+		return
+	}
+
+	var (
+		ctx RequestContext
+		err error
+	)
+	switch request.GetCOTP().GetPduType() {
+	case common.PtDisconnectRequest:
+		ctx = &ConnectRequestContext{Request: request, Callback: callback}
+		err = c.tcpClient.handleDisconnectRequestContext(ctx)
+	case common.PtConnectRequest:
+		ctx = &ConnectRequestContext{Request: request, Callback: callback}
+		err = c.tcpClient.handleConnectRequestContext(ctx)
+	default:
+		ctx = &StandardRequestContext{
+			RequestId: request.GetHeader().GetPduReference(),
+			Request:   request,
+			Callback:  callback,
 		}
-		p.err = checkReqAck(request, ack)
-		p.v = ack
-		p.flowComplete()
-	}()
-	return p
+		err = c.tcpClient.handleRequestContext(ctx)
+	}
+	if err != nil {
+		reject(err)
+		return
+	}
+	pdu := ctx.GetRequest().ToBytes()
+	// a stuck connection must not hold the worker, unsupported by the connections of gnet which do not block
+	_ = conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err = conn.Write(pdu); err != nil {
+		c.tcpClient.removeRequestContext(ctx)
+		complete(nil, err, false)
+		return
+	}
+	c.metrics.BytesSent(len(pdu))
+	c.logger.Debugf("S7 client sending: % x", pdu)
 }
 
 func (c *client) Connect() *ConnectToken {
@@ -956,29 +1745,20 @@ func (c *client) Connect() *ConnectToken {
 	}
 
 	go func() {
-		backoff := c.retryInterval
-		var conn net.Conn
-		for retries := 1; c.maxRetries == -1 || retries <= c.maxRetries; retries++ {
-			conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d", c.host, c.port), c.timeout)
-			if err == nil {
-				break
-			}
-
-			c.logger.Debugf("retrying in %v, failed to connect to %s: %v", backoff, fmt.Sprintf("%s:%d", c.host, c.port), err)
-			if backoff < c.maxRetryBackoff {
-				backoff *= 2
-			}
-			if retries != c.maxRetries {
-				time.Sleep(backoff)
-			}
-		}
+		conn, _, err := c.dial(fmt.Sprintf("%s:%d", c.host, c.port), c.connectBackoff)
 		if err != nil {
 			_ = fn(false)
 			t.setError(err)
 			c.disconnectedWithError(common.ErrorWithCode(common.ErrTcpConnect, err))
 			return
 		}
-		gc, _ := c.cli.Enroll(conn)
+		gc, err := c.transport.Attach(conn, c.tcpClient)
+		if err != nil {
+			_ = fn(false)
+			t.setError(common.ErrorWithCode(common.ErrTcpConnect, err))
+			c.disconnectedWithError(common.ErrorWithCode(common.ErrTcpConnect, err))
+			return
+		}
 		c.SetConn(gc)
 
 		c.logger.Infof("S7 client start iso connect for [%s]", fmt.Sprintf("%s:%d", c.host, c.port))
@@ -1025,9 +1805,13 @@ func (c *client) Connect() *ConnectToken {
 			return
 		}
 		c.pduLength = int(parameter.PduLength)
+		c.scheduler.setSlots(int(parameter.MaxAmqCaller))
 
 		c.logger.Infof("S7 client for [%s] is active", fmt.Sprintf("%s:%d", c.host, c.port))
 		_ = fn(true)
+		if c.heartbeatInterval > 0 {
+			c.heartbeat(gc)
+		}
 		t.v = c
 		t.flowComplete()
 		go util.Invoke(c.onConnected, []interface{}{(*Client)(nil)}, c)
@@ -1035,12 +1819,18 @@ func (c *client) Connect() *ConnectToken {
 	return t
 }
 
-func (c *client) tcpOnOpen(gnet.Conn) {
-	return
-}
-
-func (c *client) tcpOnClose(_ gnet.Conn, err error) {
+func (c *client) tcpOnClose(err error) {
+	c.m.Lock()
+	if err == nil {
+		err = c.deadCause
+	}
+	c.deadCause = nil
+	c.m.Unlock()
 	c.disconnectedWithError(err)
+	// nothing waiting or in flight is written to or answered on the closed connection
+	closed := common.ErrorWithCode(common.ErrCliConnectionNil, c.host, c.port)
+	c.scheduler.cancel(closed)
+	c.tcpClient.failRequestContexts(closed)
 	disFn, err := c.status.ConnectionLost(c.autoReconnect && c.status.ConnectionStatus() > connecting)
 	if err != nil {
 		return
@@ -1062,31 +1852,17 @@ func (c *client) disconnectedWithError(err error) {
 
 func (c *client) reconnect(connectionUp connCompletedFn) {
 	c.logger.Debugf("client start reconnect")
-	var (
-		conn net.Conn
-		err  error
-	)
-
-	backoff := c.reconnectInterval
-	for retries := 1; c.maxReconnectTimes == -1 || retries <= c.maxReconnectTimes; retries++ {
-		conn, err = net.DialTimeout("tcp", fmt.Sprintf("%s:%d", c.host, c.port), c.timeout)
-		if err == nil {
-			break
-		}
-
-		c.logger.Debugf("retrying in %v, failed to connect to %s: %v", backoff, fmt.Sprintf("%s:%d", c.host, c.port), err)
-		if backoff < c.maxReconnectBackoff {
-			backoff *= 2
-		}
-		if retries != c.maxReconnectTimes {
-			time.Sleep(backoff)
-		}
+	c.metrics.Reconnect()
+	conn, _, err := c.dial(fmt.Sprintf("%s:%d", c.host, c.port), c.reconnectBackoff)
+	if err != nil {
+		_ = connectionUp(false)
+		return
 	}
+	gc, err := c.transport.Attach(conn, c.tcpClient)
 	if err != nil {
 		_ = connectionUp(false)
 		return
 	}
-	gc, _ := c.cli.Enroll(conn)
 	c.SetConn(gc)
 
 	c.logger.Infof("S7 client start iso connect for [%s]", fmt.Sprintf("%s:%d", c.host, c.port))
@@ -1121,9 +1897,13 @@ func (c *client) reconnect(connectionUp connCompletedFn) {
 		return
 	}
 	c.pduLength = int(parameter.PduLength)
+	c.scheduler.setSlots(int(parameter.MaxAmqCaller))
 
 	c.logger.Infof("S7 client for [%s] is active", fmt.Sprintf("%s:%d", c.host, c.port))
 	_ = connectionUp(true)
+	if c.heartbeatInterval > 0 {
+		c.heartbeat(gc)
+	}
 	go util.Invoke(c.onConnected, []interface{}{(*Client)(nil)}, c)
 }
 
@@ -1138,9 +1918,8 @@ func (c *client) Disconnect() {
 }
 
 func (c *client) disconnect() {
-	if c.cli != nil {
-		_ = c.cli.Stop()
-		c.cli = nil
+	if c.ownTransport {
+		_ = c.transport.Close()
 	}
 	if conn := c.GetConn(); conn != nil {
 		_ = conn.Close()
@@ -1175,65 +1954,304 @@ func siemensTimestamp(encodedDate int64) time.Time {
 		Add(time.Second * time.Duration(encodedDate*86400))
 }
 
-func (c *client) parseReadRequestItems(addresses []string) (items []common.RequestItem, ots []common.ParamVariableType, err error) {
+// parseReadRequestItems parse the addresses to the request items, done is called with the items once the lengths of
+// strings without declared max length are read from PLC, by one request for all of them
+func (c *client) parseReadRequestItems(addresses []string, priority Priority,
+	done func(items []common.RequestItem, infos []RawInfo, err error)) {
 	if len(addresses) == 0 {
-		err = common.ErrorWithCode(common.ErrAddressEmpty)
+		done(nil, nil, common.ErrorWithCode(common.ErrAddressEmpty))
 		return
 	}
-	items = make([]common.RequestItem, 0)
-	ots = make([]common.ParamVariableType, 0, len(addresses))
-	var item common.RequestItem
-	for i := 0; i < len(addresses); i++ {
-		address := addresses[i]
-		item, err = core.ParseAddress(address)
+	items := make([]common.RequestItem, 0, len(addresses))
+	infos := make([]RawInfo, 0, len(addresses))
+	probes := make([]*lengthProbe, 0)
+	for _, address := range addresses {
+		item, info, probe, err := c.parseReadRequestItem(address)
 		if err != nil {
+			done(nil, nil, err)
 			return
 		}
-		ot := item.(*core.StandardRequestItem).VariableType
-		err = c.parseRequestItem(item.(*core.StandardRequestItem))
+		if probe != nil {
+			probes = append(probes, probe)
+		}
+		items = append(items, item)
+		infos = append(infos, info)
+	}
+	c.readLengthProbes(probes, priority, func(errs []error, err error) {
+		if err == nil {
+			err = errors.Join(errs...)
+		}
 		if err != nil {
+			done(nil, nil, err)
 			return
 		}
-		items = append(items, item)
-		ots = append(ots, ot)
+		done(items, infos, nil)
+	})
+}
+
+// parseReadRequestItem parse the address to the request item,
+// the probe is set if the length of a string has to be read before the item
+func (c *client) parseReadRequestItem(address string) (item common.RequestItem, info RawInfo, probe *lengthProbe, err error) {
+	item, err = c.parseAddress(address)
+	if err != nil {
+		return
 	}
+	requestItem := item.(*core.StandardRequestItem)
+	info = RawInfo{
+		Type:      requestItem.VariableType,
+		plcType:   c.plcType,
+		charset:   c.charset,
+		bitOffset: requestItem.BitAddress,
+	}
+	if requestItem.Array {
+		info.Count = int(requestItem.Count)
+	}
+	probe, err = c.parseRequestItem(address, requestItem)
 	return
 }
 
-func (c *client) parsesWriteRequestItems(addresses []string, data [][]byte) (requests []common.RequestItem, dataItems []common.ResponseItem, err error) {
+// parsesWriteRequestItems parse the addresses and data to the request and data items, done is called with the items
+// once the max lengths of strings not declared by the addresses are read from PLC, by one request for all of them
+func (c *client) parsesWriteRequestItems(addresses []string, data [][]byte, priority Priority,
+	done func(requests []common.RequestItem, dataItems []common.ResponseItem, err error)) {
 	if len(addresses) == 0 {
-		err = common.ErrorWithCode(common.ErrAddressEmpty)
+		done(nil, nil, common.ErrorWithCode(common.ErrAddressEmpty))
 		return
 	}
 	if len(addresses) != len(data) {
-		err = common.ErrorWithCode(common.ErrCliRequestDataDifferent)
+		done(nil, nil, common.ErrorWithCode(common.ErrCliRequestDataDifferent))
 		return
 	}
-
-	var item common.RequestItem
-	requests = make([]common.RequestItem, 0)
-	dataItems = make([]common.ResponseItem, 0)
-	for i := 0; i < len(addresses); i++ {
-		address := addresses[i]
-		item, err = core.ParseAddress(address)
+	requests := make([]common.RequestItem, 0)
+	dataItems := make([]common.ResponseItem, 0)
+	probes := make([]*lengthProbe, 0)
+	for i, address := range addresses {
+		itemRequests, itemDataItems, probe, err := c.parseWriteRequestItem(address, data[i])
+		if err != nil {
+			done(nil, nil, err)
+			return
+		}
+		if probe != nil {
+			probes = append(probes, probe)
+		}
+		requests = append(requests, itemRequests...)
+		dataItems = append(dataItems, itemDataItems...)
+	}
+	c.readLengthProbes(probes, priority, func(errs []error, err error) {
+		if err == nil {
+			err = errors.Join(errs...)
+		}
 		if err != nil {
+			done(nil, nil, err)
 			return
 		}
-		if item.(*core.StandardRequestItem).VariableType == common.PvtString || item.(*core.StandardRequestItem).VariableType == common.PvtWString {
-			item.(*core.StandardRequestItem).Count = item.(*core.StandardRequestItem).Count * uint16(len(data[i]))
-			item.(*core.StandardRequestItem).VariableType = common.PvtByte
-		} else if item.(*core.StandardRequestItem).VariableType != common.PvtBit {
-			item.(*core.StandardRequestItem).Count = item.(*core.StandardRequestItem).Count * item.(*core.StandardRequestItem).VariableType.Size()
-			item.(*core.StandardRequestItem).VariableType = common.PvtByte
+		done(requests, dataItems, nil)
+	})
+}
+
+// parseWriteRequestItem parse the address and data to the request and data items, arrays may be split into several,
+// the probe is set if the max length of a string has to be read and checked before the write
+func (c *client) parseWriteRequestItem(address string, data []byte) (requests []common.RequestItem,
+	dataItems []common.ResponseItem, probe *lengthProbe, err error) {
+	item, err := c.parseAddress(address)
+	if err != nil {
+		return
+	}
+	requestItem := item.(*core.StandardRequestItem)
+	if requestItem.Array {
+		requests, dataItems, err = parseWriteArrayItem(address, requestItem, data)
+		return
+	}
+	if requestItem.VariableType == common.PvtString || requestItem.VariableType == common.PvtWString {
+		if data, probe, err = c.parseWriteStringItem(address, requestItem, data); err != nil {
+			return
+		}
+	} else if requestItem.VariableType != common.PvtBit && requestItem.VariableType.DataVariableType() != common.DvtOctetString {
+		// timers and counters are written by their number
+		requestItem.Count = requestItem.Count * requestItem.VariableType.Size()
+		requestItem.VariableType = common.PvtByte
+	}
+	requests = []common.RequestItem{item}
+	dataItems = []common.ResponseItem{core.NewReqDataItem(data, requestItem.VariableType.DataVariableType())}
+	return
+}
+
+// encodeValues encode the values by the type of the addresses
+func (c *client) encodeValues(addresses []string, values []any) ([][]byte, error) {
+	if len(addresses) != len(values) {
+		return nil, common.ErrorWithCode(common.ErrCliRequestDataDifferent)
+	}
+	e := encoder{plcType: c.plcType, pduLength: c.pduLength, charset: c.charset}
+	data := make([][]byte, 0, len(values))
+	for i, address := range addresses {
+		item, err := c.parseAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := e.Encode(item.(*core.StandardRequestItem), values[i])
+		if err != nil {
+			return nil, common.ErrorWithCode(common.ErrCliValueInvalid, values[i], address, err)
+		}
+		data = append(data, bs)
+	}
+	return data, nil
+}
+
+// parseWriteStringItem check the string against the max length declared in PLC and keep the max length header,
+// the max length is read from PLC by the returned probe unless the address declares it, e.g. DB1.S10[254]
+// returns the data to write, starting at the length of the string
+func (c *client) parseWriteStringItem(address string, item *core.StandardRequestItem, data []byte) ([]byte, *lengthProbe, error) {
+	// header size of max length and length
+	maxSize, lengthSize, charSize := 1, 1, 1
+	if item.VariableType == common.PvtWString {
+		maxSize, lengthSize, charSize = 2, 2, 2
+	}
+	if c.plcType == common.S200Smart {
+		// S7-200 smart strings have no max length, wide strings one byte each
+		maxSize, lengthSize = 0, 1
+		if item.VariableType == common.PvtWString {
+			maxSize = 1
+		}
+	}
+	headerSize := maxSize + lengthSize
+	if len(data) < headerSize {
+		return nil, nil, common.ErrorWithCode(common.ErrCliRequestDataInvalid, address, headerSize)
+	}
+	length := int(data[maxSize])
+	if lengthSize == 2 {
+		length = int(binary.BigEndian.Uint16(data[maxSize:]))
+	}
+	if len(data) < headerSize+length*charSize {
+		return nil, nil, common.ErrorWithCode(common.ErrCliRequestDataInvalid, address, headerSize+length*charSize)
+	}
+	data = data[maxSize : headerSize+length*charSize]
+
+	var probe *lengthProbe
+	if item.MaxLength == 0 && maxSize > 0 {
+		probe = &lengthProbe{address: address, header: *item, set: func(header []byte) error {
+			if len(header) < maxSize {
+				return common.ErrorWithCode(common.ErrCliResponseInvalid)
+			}
+			maxLength := int(header[0])
+			if maxSize == 2 {
+				maxLength = int(binary.BigEndian.Uint16(header))
+			}
+			if maxLength > 0 && length > maxLength {
+				return common.ErrorWithCode(common.ErrCliStringTooLong, address, length, maxLength)
+			}
+			return nil
+		}}
+		probe.header.VariableType = common.PvtByte
+		probe.header.Count = uint16(maxSize)
+	} else if maxLength := int(item.MaxLength); maxLength > 0 && length > maxLength {
+		return nil, nil, common.ErrorWithCode(common.ErrCliStringTooLong, address, length, maxLength)
+	}
+	item.ByteAddress += maxSize
+	item.Count = uint16(len(data))
+	item.VariableType = common.PvtByte
+	return data, probe, nil
+}
+
+func (c *client) checkTags(names []string) error {
+	for _, name := range names {
+		if c.tags == nil {
+			return common.ErrorWithCode(common.ErrTagNotFound, name)
+		}
+		if _, ok := c.tags.Get(name); !ok {
+			return common.ErrorWithCode(common.ErrTagNotFound, name)
+		}
+	}
+	return nil
+}
+
+// parseAddress resolve tag name from tag table, otherwise parse as address
+func (c *client) parseAddress(address string) (requestItem common.RequestItem, err error) {
+	if tag, ok := c.tag(address); ok {
+		requestItem, err = tag.RequestItem()
+	} else {
+		requestItem, err = core.ParseAddress(address)
+	}
+	if err != nil {
+		err = common.WithAddress(err, address)
+		return
+	}
+	if c.plcType == common.S200 || c.plcType == common.S200Smart {
+		iecItem(requestItem.(*core.StandardRequestItem))
+	}
+	return
+}
+
+func (c *client) tag(name string) (core.Tag, bool) {
+	if c.tags == nil {
+		return core.Tag{}, false
+	}
+	return c.tags.Get(name)
+}
+
+// iecItem timers and counters of S7-200 are IEC timers and counters
+func iecItem(item *core.StandardRequestItem) {
+	switch item.Area {
+	case common.AtTimers:
+		item.Area = common.AtIecTimers
+		item.VariableType = common.PvtIecTimer
+	case common.AtCounters:
+		item.Area = common.AtIecCounters
+		item.VariableType = common.PvtIecCounter
+	}
+}
+
+// parseWriteArrayItem split array into write items, data of bit array is packed 8 bits per byte
+// bit arrays not aligned to whole bytes are written bit by bit
+func parseWriteArrayItem(address string, item *core.StandardRequestItem, data []byte) (requests []common.RequestItem, dataItems []common.ResponseItem, err error) {
+	count := int(item.Count)
+	if item.VariableType != common.PvtBit {
+		size := count * int(item.VariableType.Size())
+		if len(data) != size {
+			err = common.ErrorWithCode(common.ErrCliRequestDataInvalid, address, size)
+			return
 		}
-		dataItem := core.NewReqDataItem(data[i], item.(*core.StandardRequestItem).VariableType.DataVariableType())
+		item.Count = uint16(size)
+		item.VariableType = common.PvtByte
+		requests = append(requests, item)
+		dataItems = append(dataItems, core.NewReqDataItem(data, item.VariableType.DataVariableType()))
+		return
+	}
+
+	if len(data) != (count+7)/8 {
+		err = common.ErrorWithCode(common.ErrCliRequestDataInvalid, address, (count+7)/8)
+		return
+	}
+	if item.BitAddress == 0 && count%8 == 0 {
+		item.Count = uint16(count / 8)
+		item.VariableType = common.PvtByte
 		requests = append(requests, item)
-		dataItems = append(dataItems, dataItem)
+		dataItems = append(dataItems, core.NewReqDataItem(data, item.VariableType.DataVariableType()))
+		return
+	}
+	for i := 0; i < count; i++ {
+		bit := item.BitAddress + i
+		bitItem := core.NewStandardRequestItem(item.Area, int(item.DbNumber), common.PvtBit, item.ByteAddress+bit/8, bit%8, 1)
+		value := Bit(util.GetBoolAt(data[i/8], uint(i%8)))
+		requests = append(requests, bitItem)
+		dataItems = append(dataItems, core.NewReqDataItem(value.ToBytes(), bitItem.VariableType.DataVariableType()))
 	}
 	return
 }
 
-func (c *client) parseRequestItem(item *core.StandardRequestItem) (err error) {
+// parseRequestItem set the item to read bytes, strings without declared max length are read with the header of
+// max length and length by the returned probe first, which sets the count of the item by the length
+func (c *client) parseRequestItem(address string, item *core.StandardRequestItem) (probe *lengthProbe, err error) {
+	if item.Array {
+		// arrays are read as bytes, bit arrays from the byte of the first bit
+		if item.VariableType == common.PvtBit {
+			item.Count = uint16((item.BitAddress + int(item.Count) + 7) / 8)
+			item.BitAddress = 0
+		} else {
+			item.Count = item.VariableType.Size() * item.Count
+		}
+		item.VariableType = common.PvtByte
+		return
+	}
 	switch item.VariableType {
 	case common.PvtString:
 		item.VariableType = common.PvtByte
@@ -1241,18 +2259,19 @@ func (c *client) parseRequestItem(item *core.StandardRequestItem) (err error) {
 		if c.plcType == common.S200Smart {
 			count = 1
 		}
-		item.Count = uint16(count)
-		var lRes []*core.DataItem
-		token := c.read([]common.RequestItem{item})
-		lRes, err = token.Wait()
-		if err != nil {
-			return
-		}
-		if len(lRes) == 0 {
-			err = common.ErrorWithCode(common.ErrCliResponseInvalid)
-			return
+		if item.MaxLength > 0 {
+			// declared max length, read header and content at once
+			item.Count = uint16(count) + item.MaxLength
+			break
 		}
-		item.Count = uint16(count) + uint16(lRes[0].Data[count-1])
+		item.Count = uint16(count)
+		probe = &lengthProbe{address: address, header: *item, set: func(header []byte) error {
+			if len(header) < count {
+				return common.ErrorWithCode(common.ErrCliResponseInvalid)
+			}
+			item.Count = uint16(count) + uint16(header[count-1])
+			return nil
+		}}
 		break
 	case common.PvtWString:
 		item.VariableType = common.PvtByte
@@ -1260,20 +2279,22 @@ func (c *client) parseRequestItem(item *core.StandardRequestItem) (err error) {
 		if c.plcType == common.S200Smart {
 			count = 2
 		}
-		item.Count = uint16(count)
-		var lRes []*core.DataItem
-		token := c.read([]common.RequestItem{item})
-		lRes, err = token.Wait()
-		if err != nil {
-			return
-		}
-		if len(lRes) == 0 {
-			err = common.ErrorWithCode(common.ErrCliResponseInvalid)
-			return
+		if item.MaxLength > 0 {
+			item.Count = uint16(count) + item.MaxLength*2
+			break
 		}
-		item.Count = uint16(count) + binary.BigEndian.Uint16(lRes[0].Data[count-2:])*2
+		item.Count = uint16(count)
+		probe = &lengthProbe{address: address, header: *item, set: func(header []byte) error {
+			if len(header) < count {
+				return common.ErrorWithCode(common.ErrCliResponseInvalid)
+			}
+			item.Count = uint16(count) + binary.BigEndian.Uint16(header[count-2:])*2
+			return nil
+		}}
 		break
-	case common.PvtTime, common.PvtDate, common.PvtTimeOfDay, common.PvtDateTime, common.PvtS5Time, common.PvtDTL:
+	case common.PvtTime, common.PvtDate, common.PvtTimeOfDay, common.PvtDateTime, common.PvtS5Time, common.PvtDTL,
+		common.PvtSInt, common.PvtUSInt, common.PvtUInt, common.PvtUDInt, common.PvtLInt, common.PvtULInt, common.PvtLWord,
+		common.PvtLReal, common.PvtLTime, common.PvtLTimeOfDay, common.PvtLDT, common.PvtWChar:
 		item.Count = item.VariableType.Size() * item.Count
 		item.VariableType = common.PvtByte
 		break
@@ -1281,6 +2302,44 @@ func (c *client) parseRequestItem(item *core.StandardRequestItem) (err error) {
 	return
 }
 
+// lengthProbe header of a string read from PLC before the string is read or written
+type lengthProbe struct {
+	// address of the string
+	address string
+	// header request item of the max length and length
+	header core.StandardRequestItem
+	// set the item of the string by the header read
+	set func(header []byte) error
+}
+
+// readLengthProbes read the headers of the probes by one request, done is called with the error of each probe,
+// or the error if the request failed at all
+func (c *client) readLengthProbes(probes []*lengthProbe, priority Priority, done func(errs []error, err error)) {
+	if len(probes) == 0 {
+		done(nil, nil)
+		return
+	}
+	headers := make([]common.RequestItem, 0, len(probes))
+	for _, probe := range probes {
+		headers = append(headers, &probe.header)
+	}
+	c.readItems(headers, priority).then(func(v []*core.DataItem, err error) {
+		if err != nil {
+			done(nil, err)
+			return
+		}
+		errs := make([]error, len(probes))
+		for i, dataItem := range v {
+			if dataItem.ReturnCode != common.RcSuccess {
+				errs[i] = common.ReturnCodeError{ReturnCode: dataItem.ReturnCode, Address: probes[i].address}
+				continue
+			}
+			errs[i] = probes[i].set(dataItem.Data)
+		}
+		done(errs, nil)
+	})
+}
+
 func (c *client) GeneratePduNumber() uint16 {
 	index := c.IncrementAndGetPduIndex()
 	if index >= 65536 {
@@ -1294,9 +2353,9 @@ func (c *client) IncrementAndGetPduIndex() uint32 {
 	return atomic.AddUint32(&c.pduIndex, 1)
 }
 
-func (c *client) SetConn(conn gnet.Conn) {
-	c.m.RLock()
-	defer c.m.RUnlock()
+func (c *client) SetConn(conn net.Conn) {
+	c.m.Lock()
+	defer c.m.Unlock()
 	c.conn = conn
 }
 
@@ -1309,3 +2368,7 @@ func (c *client) GetConn() net.Conn {
 func (c *client) GetPduLength() int {
 	return c.pduLength
 }
+
+func (c *client) GetCharset() common.Charset {
+	return c.charset
+}
@@ -0,0 +1,467 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+)
+
+// areaTypes supported block areas and their gs7 area constant
+var areaTypes = map[string]string{
+	"DB": "common.AtDataBlocks",
+	"I":  "common.AtInputs",
+	"Q":  "common.AtOutputs",
+	"M":  "common.AtFlags",
+}
+
+// tagAreaTypes areas of PLC tags which can be read by byte range
+var tagAreaTypes = map[common.AreaType]string{
+	common.AtInputs:  "common.AtInputs",
+	common.AtOutputs: "common.AtOutputs",
+	common.AtFlags:   "common.AtFlags",
+}
+
+type generator struct {
+	body    bytes.Buffer
+	imports map[string]bool
+	// charset expression of the charset of strings in the generated function
+	charset string
+	// fail statement returning err from the generated function
+	fail string
+}
+
+func newGenerator() *generator {
+	return &generator{imports: map[string]bool{}}
+}
+
+func (g *generator) p(format string, args ...any) {
+	_, _ = fmt.Fprintf(&g.body, format, args...)
+	g.body.WriteByte('\n')
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+// source assemble generated go source with used imports and format it
+func (g *generator) source(pkg string, input string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gs7-gen. DO NOT EDIT.\n")
+	_, _ = fmt.Fprintf(&buf, "// source: %s\n\n", input)
+	_, _ = fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		buf.WriteString("import (\n")
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(&buf, "\t%q\n", path)
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.Write(g.body.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+// GenerateLayout generate structs, codecs and block accessors of layout
+func (g *generator) GenerateLayout(defs []*structDef) {
+	for _, def := range defs {
+		g.structType(def)
+		g.decodeFunc(def)
+		g.encodeFunc(def)
+		if def.block {
+			g.blockAccessor(def)
+		}
+	}
+}
+
+func (g *generator) structType(def *structDef) {
+	if def.block {
+		g.p("// %s %s, %d bytes", def.name, blockAddress(def), def.size)
+	} else {
+		g.p("// %s UDT %s, %d bytes", def.name, def.plcName, def.size)
+	}
+	if def.comment != "" {
+		g.p("// %s", def.comment)
+	}
+	g.p("type %s struct {", def.name)
+	for _, f := range def.fields {
+		if f.comment != "" {
+			g.p("// %s %s", f.name, f.comment)
+		}
+		if strings.HasPrefix(f.typ.goType(), "time.") {
+			g.use("time")
+		}
+		g.p("%s %s // %s", f.name, f.goType(), offsetString(f))
+	}
+	g.p("}")
+	g.p("")
+	g.p("// %sSize size of %s in bytes", def.name, def.name)
+	g.p("const %sSize = %d", def.name, def.size)
+	g.p("")
+}
+
+func (g *generator) decodeFunc(def *structDef) {
+	g.use("fmt")
+	g.use("github.com/shiyuecamus/gs7/common")
+	g.charset = "charset"
+	g.p("// Decode%s decode %s from bytes, strings in the charset", def.name, def.name)
+	g.p("func Decode%s(bs []byte, charset common.Charset) (v %s, err error) {", def.name, def.name)
+	g.p("if len(bs) < %sSize {", def.name)
+	g.p(`err = fmt.Errorf("invalid bytes for %s")`, def.name)
+	g.p("return")
+	g.p("}")
+	for _, f := range def.fields {
+		g.decodeField(f, "v."+f.name, f.byteOff, f.bitOff)
+	}
+	g.p("return")
+	g.p("}")
+	g.p("")
+}
+
+func (g *generator) encodeFunc(def *structDef) {
+	g.use("github.com/shiyuecamus/gs7/common")
+	g.charset, g.fail = "charset", "return nil, err"
+	g.p("// Encode%s encode %s to bytes, gaps between fields are zero,", def.name, def.name)
+	g.p("// strings in the charset, characters not in the charset are an error")
+	g.p("func Encode%s(v %s, charset common.Charset) ([]byte, error) {", def.name, def.name)
+	g.p("bs := make([]byte, %sSize)", def.name)
+	for _, f := range def.fields {
+		g.encodeField(f, "v."+f.name, f.byteOff, f.bitOff)
+	}
+	g.p("return bs, nil")
+	g.p("}")
+	g.p("")
+}
+
+// decodeField decode field at byte offset into target
+func (g *generator) decodeField(f *field, target string, byteOff int, bitOff int) {
+	switch {
+	case f.count > 0 && f.typ.isBool():
+		g.p("for i := 0; i < %d; i++ {", f.count)
+		g.p("%s[i] = bs[%d+(i+%d)/8]&(1<<((i+%d)%%8)) != 0", target, byteOff, bitOff, bitOff)
+		g.p("}")
+	case f.count > 0:
+		g.p("for i := 0; i < %d; i++ {", f.count)
+		g.decodeElem(f.typ, target+"[i]", fmt.Sprintf("%d+i*%d", byteOff, f.elemSize()), 0)
+		g.p("}")
+	default:
+		g.decodeElem(f.typ, target, strconv.Itoa(byteOff), bitOff)
+	}
+}
+
+func (g *generator) decodeElem(t *fieldType, target string, off string, bitOff int) {
+	switch {
+	case t.isBool():
+		g.p("%s = bs[%s]&0x%02x != 0", target, off, 1<<bitOff)
+	case t.udt != nil:
+		g.p("if %s, err = Decode%s(bs[%s:], %s); err != nil {", target, t.udt.name, off, g.charset)
+		g.p("return")
+		g.p("}")
+	case t.wide:
+		g.use("github.com/shiyuecamus/gs7")
+		g.use("github.com/shiyuecamus/gs7/common")
+		g.p("{")
+		g.p("n := int(bs[%s])<<8 | int(bs[%s])", offset(off, 2), offset(off, 3))
+		g.p("if n > %d {", t.strLen)
+		g.p(`err = fmt.Errorf("invalid string length %%d", n)`)
+		g.p("return")
+		g.p("}")
+		g.p("var s gs7.WString")
+		g.p("if s, err = gs7.WStringFromBytes(bs[%s:%s+n*2], common.S1500); err != nil {", off, offset(off, 4))
+		g.p("return")
+		g.p("}")
+		g.p("%s = string(s)", target)
+		g.p("}")
+	case t.str:
+		g.use("github.com/shiyuecamus/gs7")
+		g.use("github.com/shiyuecamus/gs7/common")
+		g.p("{")
+		g.p("n := int(bs[%s])", offset(off, 1))
+		g.p("if n > %d {", t.strLen)
+		g.p(`err = fmt.Errorf("invalid string length %%d", n)`)
+		g.p("return")
+		g.p("}")
+		g.p("var s gs7.String")
+		g.p("if s, err = gs7.StringFromBytesWithCharset(bs[%s:%s+n], common.S1500, %s); err != nil {", off, offset(off, 2), g.charset)
+		g.p("return")
+		g.p("}")
+		g.p("%s = string(s)", target)
+		g.p("}")
+	default:
+		g.use("github.com/shiyuecamus/gs7")
+		g.p("{")
+		g.p("var x gs7.%s", t.scalar.codec)
+		g.p("if x, err = gs7.%sFromBytes(bs[%s:%s]); err != nil {", t.scalar.codec, off, offset(off, t.scalar.size))
+		g.p("return")
+		g.p("}")
+		g.p("%s = %s(x)", target, t.scalar.goType)
+		g.p("}")
+	}
+}
+
+// encodeField encode source field to bs at byte offset
+func (g *generator) encodeField(f *field, src string, byteOff int, bitOff int) {
+	switch {
+	case f.count > 0 && f.typ.isBool():
+		g.p("for i := 0; i < %d; i++ {", f.count)
+		g.p("if %s[i] {", src)
+		g.p("bs[%d+(i+%d)/8] |= 1 << ((i + %d) %% 8)", byteOff, bitOff, bitOff)
+		g.p("}")
+		g.p("}")
+	case f.count > 0:
+		g.p("for i := 0; i < %d; i++ {", f.count)
+		g.encodeElem(f.typ, src+"[i]", fmt.Sprintf("%d+i*%d", byteOff, f.elemSize()), 0)
+		g.p("}")
+	default:
+		g.encodeElem(f.typ, src, strconv.Itoa(byteOff), bitOff)
+	}
+}
+
+func (g *generator) encodeElem(t *fieldType, src string, off string, bitOff int) {
+	switch {
+	case t.isBool():
+		g.p("if %s {", src)
+		g.p("bs[%s] |= 0x%02x", off, 1<<bitOff)
+		g.p("}")
+	case t.udt != nil:
+		g.p("{")
+		g.p("s, err := Encode%s(%s, %s)", t.udt.name, src, g.charset)
+		g.p("if err != nil {")
+		g.p(g.fail)
+		g.p("}")
+		g.p("copy(bs[%s:], s)", off)
+		g.p("}")
+	case t.wide:
+		g.use("github.com/shiyuecamus/gs7")
+		g.p("{")
+		g.p("s := gs7.WString(%s).ToBytes(0)", src)
+		g.p("if len(s) > %d {", 4+2*t.strLen)
+		g.p("s = s[:%d]", 4+2*t.strLen)
+		g.p("}")
+		g.p("n := (len(s) - 4) / 2")
+		g.p("s[0], s[1], s[2], s[3] = %d, %d, byte(n>>8), byte(n)", t.strLen>>8, t.strLen&0xFF)
+		g.p("copy(bs[%s:], s)", off)
+		g.p("}")
+	case t.str:
+		g.use("github.com/shiyuecamus/gs7")
+		g.p("{")
+		g.p("s, err := gs7.String(%s).Encode(0, %s)", src, g.charset)
+		g.p("if err != nil {")
+		g.p(g.fail)
+		g.p("}")
+		g.p("if len(s) > %d {", 2+t.strLen)
+		g.p("s = s[:%d]", 2+t.strLen)
+		g.p("}")
+		g.p("s[0], s[1] = %d, byte(len(s)-2)", t.strLen)
+		g.p("copy(bs[%s:], s)", off)
+		g.p("}")
+	default:
+		g.use("github.com/shiyuecamus/gs7")
+		g.p("copy(bs[%s:], gs7.%s(%s).ToBytes())", off, t.scalar.codec, src)
+	}
+}
+
+func (g *generator) blockAccessor(def *structDef) {
+	g.use("github.com/shiyuecamus/gs7")
+	g.use("github.com/shiyuecamus/gs7/common")
+	area := areaTypes[def.area]
+	name := def.name + "Block"
+
+	g.p("// %s typed accessor of %s", name, blockAddress(def))
+	g.p("type %s struct {", name)
+	g.p("c gs7.Client")
+	if def.area == "DB" {
+		g.p("// DB data block number, default %d", def.db)
+		g.p("DB int")
+	}
+	g.p("}")
+	g.p("")
+	g.p("func New%s(c gs7.Client) *%s {", name, name)
+	if def.area == "DB" {
+		g.p("return &%s{c: c, DB: %d}", name, def.db)
+	} else {
+		g.p("return &%s{c: c}", name)
+	}
+	g.p("}")
+	g.p("")
+
+	db := "0"
+	if def.area == "DB" {
+		db = "b.DB"
+	}
+	// strings are in the charset of the client
+	g.charset, g.fail = "b.c.GetCharset()", "return err"
+	g.p("// Read read whole %s", def.name)
+	g.p("func (b *%s) Read() (v %s, err error) {", name, def.name)
+	g.p("bs, err := b.c.BaseRead(%s, %s, %d, 0, %sSize).Wait()", area, db, def.start, def.name)
+	g.p("if err != nil {")
+	g.p("return")
+	g.p("}")
+	g.p("return Decode%s(bs, b.c.GetCharset())", def.name)
+	g.p("}")
+	g.p("")
+	g.p("// Write write whole %s", def.name)
+	g.p("func (b *%s) Write(v %s) error {", name, def.name)
+	g.p("bs, err := Encode%s(v, b.c.GetCharset())", def.name)
+	g.p("if err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("return b.c.BaseWrite(%s, %s, %d, 0, bs).Wait()", area, db, def.start)
+	g.p("}")
+	g.p("")
+
+	for _, f := range def.fields {
+		byteAddr := def.start + f.byteOff
+		g.p("// Read%s read %s %s", f.name, f.plcName, offsetString(f))
+		g.p("func (b *%s) Read%s() (v %s, err error) {", name, f.name, f.goType())
+		g.p("bs, err := b.c.BaseRead(%s, %s, %d, 0, %d).Wait()", area, db, byteAddr, f.size())
+		g.p("if err != nil {")
+		g.p("return")
+		g.p("}")
+		g.decodeField(f, "v", 0, f.bitOff)
+		g.p("return")
+		g.p("}")
+		g.p("")
+
+		g.p("// Write%s write %s %s", f.name, f.plcName, offsetString(f))
+		g.p("func (b *%s) Write%s(v %s) error {", name, f.name, f.goType())
+		if f.typ.isBool() && f.count == 0 {
+			g.use("fmt")
+			if def.area == "DB" {
+				g.p(`return b.c.WriteRaw(fmt.Sprintf("DB%%d.X%d.%d", b.DB), gs7.Bit(v).ToBytes()).Wait()`, byteAddr, f.bitOff)
+			} else {
+				g.p(`return b.c.WriteRaw("%sX%d.%d", gs7.Bit(v).ToBytes()).Wait()`, def.area, byteAddr, f.bitOff)
+			}
+		} else {
+			g.p("bs := make([]byte, %d)", f.size())
+			g.encodeField(f, "v", 0, f.bitOff)
+			g.p("return b.c.BaseWrite(%s, %s, %d, 0, bs).Wait()", area, db, byteAddr)
+		}
+		g.p("}")
+		g.p("")
+	}
+}
+
+// GenerateTags generate accessor of tags imported from PLC tag table
+func (g *generator) GenerateTags(name string, tags []core.Tag) error {
+	g.use("github.com/shiyuecamus/gs7")
+	g.p("// %s typed accessor of PLC tags", name)
+	g.p("type %s struct {", name)
+	g.p("c gs7.Client")
+	g.p("}")
+	g.p("")
+	g.p("func New%s(c gs7.Client) *%s {", name, name)
+	g.p("return &%s{c: c}", name)
+	g.p("}")
+	g.p("")
+
+	names := map[string]bool{}
+	for _, tag := range tags {
+		item, err := tag.RequestItem()
+		if err != nil {
+			return fmt.Errorf("tag [%s]: %w", tag.Name, err)
+		}
+		field := exportName(tag.Name)
+		if field == "" || names[field] {
+			return fmt.Errorf("tag [%s] name is empty or duplicated", tag.Name)
+		}
+		names[field] = true
+
+		dataType := strings.ToUpper(strings.Trim(strings.TrimSpace(tag.DataType), "\""))
+		var typ plcType
+		if stringTypeRegexp.MatchString(dataType) {
+			// strings are read and written by address, decoded and encoded by the client
+			typ = plcType{goType: "string"}
+		} else if t, ok := plcTypes[dataType]; ok {
+			typ = t
+		} else {
+			return fmt.Errorf("tag [%s]: data type [%s] is not supported", tag.Name, tag.DataType)
+		}
+		if strings.HasPrefix(typ.goType, "time.") {
+			g.use("time")
+		}
+		comment := ""
+		if tag.Comment != "" {
+			comment = ", " + tag.Comment
+		}
+		// bools, strings, timers and counters are read by address, others by byte range of data type
+		area, byRange := tagAreaTypes[item.Area]
+		byRange = byRange && typ.size > 0 && !typ.tagOnly
+		if byRange {
+			g.use("github.com/shiyuecamus/gs7/common")
+		}
+
+		g.p("// Read%s read %s (%s %s)%s", field, tag.Name, tag.DataType, tag.Address, comment)
+		g.p("func (t *%s) Read%s() (v %s, err error) {", name, field, typ.goType)
+		switch {
+		case byRange:
+			g.p("bs, err := t.c.BaseRead(%s, 0, %d, 0, %d).Wait()", area, item.ByteAddress, typ.size)
+		default:
+			// canonical address carries the data type and max length of strings, e.g. MS100[20]
+			g.p("raw, err := t.c.ReadRaw(%q).Wait()", item.String())
+		}
+		g.p("if err != nil {")
+		g.p("return")
+		g.p("}")
+		switch {
+		case byRange:
+			g.p("x, err := gs7.%sFromBytes(bs)", typ.codec)
+		case typ.goType == "string":
+			// decoded by the plc type and charset of the client
+			g.p("x, err := raw.Text()")
+		default:
+			g.p("x, err := gs7.%sFromBytes(raw.Value)", typ.codec)
+		}
+		g.p("v = %s(x)", typ.goType)
+		g.p("return")
+		g.p("}")
+		g.p("")
+
+		g.p("// Write%s write %s (%s %s)%s", field, tag.Name, tag.DataType, tag.Address, comment)
+		g.p("func (t *%s) Write%s(v %s) error {", name, field, typ.goType)
+		switch {
+		case byRange:
+			g.p("return t.c.BaseWrite(%s, 0, %d, 0, gs7.%s(v).ToBytes()).Wait()", area, item.ByteAddress, typ.codec)
+		case typ.goType == "string":
+			// encoded by the plc type and charset of the client, characters not in the charset are an error
+			g.p("return t.c.WriteParsed(%q, v).Wait()", item.String())
+		default:
+			g.p("return t.c.WriteRaw(%q, gs7.%s(v).ToBytes()).Wait()", item.String(), typ.codec)
+		}
+		g.p("}")
+		g.p("")
+	}
+	return nil
+}
+
+func blockAddress(def *structDef) string {
+	if def.area == "DB" {
+		return fmt.Sprintf("DB%d", def.db)
+	}
+	return fmt.Sprintf("%s%d", def.area, def.start)
+}
+
+func offsetString(f *field) string {
+	return fmt.Sprintf("offset %d.%d", f.byteOff, f.bitOff)
+}
+
+// offset add constant to offset expression
+func offset(off string, n int) string {
+	if v, err := strconv.Atoi(off); err == nil {
+		return strconv.Itoa(v + n)
+	}
+	return fmt.Sprintf("%s+%d", off, n)
+}
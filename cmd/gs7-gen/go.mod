@@ -0,0 +1,15 @@
+module github.com/shiyuecamus/gs7/cmd/gs7-gen
+
+go 1.21
+
+require (
+	github.com/shiyuecamus/gs7 v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/spf13/cast v1.6.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+replace github.com/shiyuecamus/gs7 => ../..
@@ -0,0 +1,444 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/shiyuecamus/gs7/core"
+	"github.com/shiyuecamus/gs7/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Layout data block layout description
+type Layout struct {
+	// Package go package name of generated file
+	Package string `json:"package" yaml:"package"`
+	// Types user defined types (UDT) which can be referenced by fields
+	Types []Struct `json:"types" yaml:"types"`
+	// Blocks data blocks or memory regions
+	Blocks []Struct `json:"blocks" yaml:"blocks"`
+}
+
+// Struct UDT or block layout
+type Struct struct {
+	Name    string `json:"name" yaml:"name"`
+	Comment string `json:"comment" yaml:"comment"`
+	// Area DB, I, Q or M, default DB
+	Area string `json:"area" yaml:"area"`
+	// DB data block number
+	DB int `json:"db" yaml:"db"`
+	// Start byte address of the block in area, default 0
+	Start  int     `json:"start" yaml:"start"`
+	Fields []Field `json:"fields" yaml:"fields"`
+}
+
+// Field UDT or block member
+type Field struct {
+	Name string `json:"name" yaml:"name"`
+	// Type PLC data type, e.g. Bool, Int, Real, String[20], Array[0..3] of Int or name of a type
+	Type string `json:"type" yaml:"type"`
+	// Count array element count, 0 means scalar
+	Count int `json:"count" yaml:"count"`
+	// Offset explicit byte.bit offset, e.g. "4.0", calculated by non-optimized rules if empty
+	Offset  string `json:"offset" yaml:"offset"`
+	Comment string `json:"comment" yaml:"comment"`
+}
+
+// LoadLayout load layout from json or yaml file
+func LoadLayout(name string) (*Layout, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	layout := &Layout{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		err = json.Unmarshal(content, layout)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, layout)
+	default:
+		err = fmt.Errorf("unsupported layout file [%s]", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// plcType scalar PLC data type
+type plcType struct {
+	// goType go type of struct field
+	goType string
+	// codec gs7 codec type, e.g. Real for gs7.Real
+	codec string
+	size  int
+	// tagOnly type only addressable as tag, e.g. timers and counters
+	tagOnly bool
+}
+
+var plcTypes = map[string]plcType{
+	"BOOL":           {goType: "bool", codec: "Bit"},
+	"BYTE":           {goType: "uint8", codec: "Byte", size: 1},
+	"CHAR":           {goType: "int8", codec: "Char", size: 1},
+	"WORD":           {goType: "uint16", codec: "Word", size: 2},
+	"INT":            {goType: "int16", codec: "Int", size: 2},
+	"DWORD":          {goType: "uint32", codec: "DWord", size: 4},
+	"DINT":           {goType: "int32", codec: "DInt", size: 4},
+	"REAL":           {goType: "float32", codec: "Real", size: 4},
+	"TIME":           {goType: "time.Duration", codec: "Time", size: 4},
+	"S5TIME":         {goType: "time.Duration", codec: "S5Time", size: 2},
+	"DATE":           {goType: "time.Time", codec: "Date", size: 2},
+	"TIME_OF_DAY":    {goType: "time.Time", codec: "TimeOfDay", size: 4},
+	"TOD":            {goType: "time.Time", codec: "TimeOfDay", size: 4},
+	"DATE_AND_TIME":  {goType: "time.Time", codec: "DateTime", size: 8},
+	"DT":             {goType: "time.Time", codec: "DateTime", size: 8},
+	"DTL":            {goType: "time.Time", codec: "DateTimeLong", size: 12},
+	"TIMER":          {goType: "time.Duration", codec: "Timer", size: 2, tagOnly: true},
+	"COUNTER":        {goType: "uint16", codec: "Counter", size: 2, tagOnly: true},
+	"SINT":           {goType: "int8", codec: "SInt", size: 1},
+	"USINT":          {goType: "uint8", codec: "USInt", size: 1},
+	"UINT":           {goType: "uint16", codec: "UInt", size: 2},
+	"UDINT":          {goType: "uint32", codec: "UDInt", size: 4},
+	"LINT":           {goType: "int64", codec: "LInt", size: 8},
+	"ULINT":          {goType: "uint64", codec: "ULInt", size: 8},
+	"LWORD":          {goType: "uint64", codec: "LWord", size: 8},
+	"LREAL":          {goType: "float64", codec: "LReal", size: 8},
+	"LTIME":          {goType: "time.Duration", codec: "LTime", size: 8},
+	"LTIME_OF_DAY":   {goType: "time.Time", codec: "LTimeOfDay", size: 8},
+	"LTOD":           {goType: "time.Time", codec: "LTimeOfDay", size: 8},
+	"DATE_AND_LTIME": {goType: "time.Time", codec: "LDateTime", size: 8},
+	"LDT":            {goType: "time.Time", codec: "LDateTime", size: 8},
+	"WCHAR":          {goType: "rune", codec: "WChar", size: 2},
+}
+
+const defaultStringLength = 254
+
+// fieldType resolved field element type, exactly one of scalar, str and udt is set
+type fieldType struct {
+	scalar *plcType
+	// str STRING or WSTRING with declared max length
+	str    bool
+	wide   bool
+	strLen int
+	udt    *structDef
+}
+
+func (t *fieldType) isBool() bool {
+	return t.scalar != nil && t.scalar.codec == "Bit"
+}
+
+func (t *fieldType) goType() string {
+	switch {
+	case t.scalar != nil:
+		return t.scalar.goType
+	case t.str:
+		return "string"
+	default:
+		return t.udt.name
+	}
+}
+
+// size element size in bytes, bool is 0
+func (t *fieldType) size() int {
+	switch {
+	case t.scalar != nil:
+		return t.scalar.size
+	case t.wide:
+		return 4 + 2*t.strLen
+	case t.str:
+		return 2 + t.strLen
+	default:
+		return t.udt.size
+	}
+}
+
+type field struct {
+	name    string
+	plcName string
+	comment string
+	typ     *fieldType
+	count   int
+	byteOff int
+	bitOff  int
+}
+
+// elemSize array element stride, elements larger than one byte are word aligned
+func (f *field) elemSize() int {
+	size := f.typ.size()
+	if size > 1 {
+		size = alignUp(size, 2)
+	}
+	return size
+}
+
+// size bytes covered by the field
+func (f *field) size() int {
+	switch {
+	case f.typ.isBool() && f.count == 0:
+		return 1
+	case f.typ.isBool():
+		return (f.bitOff + f.count + 7) / 8
+	case f.count == 0:
+		return f.typ.size()
+	default:
+		return f.elemSize() * f.count
+	}
+}
+
+func (f *field) goType() string {
+	if f.count > 0 {
+		return fmt.Sprintf("[%d]%s", f.count, f.typ.goType())
+	}
+	return f.typ.goType()
+}
+
+type structDef struct {
+	name    string
+	plcName string
+	comment string
+	area    string
+	db      int
+	start   int
+	fields  []*field
+	size    int
+	// block generate accessor for the struct
+	block bool
+}
+
+var (
+	stringTypeRegexp = regexp.MustCompile(`^(W?STRING)(?:\s*\[\s*(\d+)\s*])?$`)
+	arrayTypeRegexp  = regexp.MustCompile(`^ARRAY\s*\[\s*(-?\d+)\s*\.\.\s*(-?\d+)\s*]\s+OF\s+(.+)$`)
+	offsetRegexp     = regexp.MustCompile(`^(\d+)(?:\.([0-7]))?$`)
+)
+
+type resolver struct {
+	types    map[string]*Struct
+	resolved map[string]*structDef
+	visiting map[string]bool
+}
+
+// Resolve resolve field types and calculate offsets of the layout
+func (l *Layout) Resolve() ([]*structDef, error) {
+	r := &resolver{
+		types:    map[string]*Struct{},
+		resolved: map[string]*structDef{},
+		visiting: map[string]bool{},
+	}
+	for i := range l.Types {
+		key := strings.ToUpper(l.Types[i].Name)
+		if _, ok := r.types[key]; ok {
+			return nil, fmt.Errorf("type [%s] is duplicated", l.Types[i].Name)
+		}
+		r.types[key] = &l.Types[i]
+	}
+	defs := make([]*structDef, 0, len(l.Types)+len(l.Blocks))
+	for i := range l.Types {
+		def, err := r.resolveType(l.Types[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	names := map[string]bool{}
+	for _, def := range defs {
+		names[def.name] = true
+	}
+	for i := range l.Blocks {
+		def, err := r.resolveStruct(&l.Blocks[i])
+		if err != nil {
+			return nil, err
+		}
+		if names[def.name] {
+			return nil, fmt.Errorf("block [%s] is duplicated", l.Blocks[i].Name)
+		}
+		names[def.name] = true
+		def.block = true
+		def.area = strings.ToUpper(util.StrOrDefault(l.Blocks[i].Area, "DB"))
+		if _, ok := areaTypes[def.area]; !ok {
+			return nil, fmt.Errorf("area [%s] of block [%s] is not supported", l.Blocks[i].Area, l.Blocks[i].Name)
+		}
+		if def.area == "DB" && def.db <= 0 {
+			return nil, fmt.Errorf("db number of block [%s] is invalid", l.Blocks[i].Name)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func (r *resolver) resolveType(name string) (*structDef, error) {
+	key := strings.ToUpper(strings.Trim(name, "\""))
+	if def, ok := r.resolved[key]; ok {
+		return def, nil
+	}
+	s, ok := r.types[key]
+	if !ok {
+		return nil, fmt.Errorf("type [%s] is not defined", name)
+	}
+	if r.visiting[key] {
+		return nil, fmt.Errorf("type [%s] is recursive", name)
+	}
+	r.visiting[key] = true
+	defer delete(r.visiting, key)
+	def, err := r.resolveStruct(s)
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[key] = def
+	return def, nil
+}
+
+func (r *resolver) resolveStruct(s *Struct) (*structDef, error) {
+	def := &structDef{
+		name:    exportName(s.Name),
+		plcName: s.Name,
+		comment: s.Comment,
+		db:      s.DB,
+		start:   s.Start,
+	}
+	if def.name == "" {
+		return nil, fmt.Errorf("name of struct is empty")
+	}
+	names := map[string]bool{}
+	bit := 0
+	for _, f := range s.Fields {
+		typ, count, err := r.resolveFieldType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field [%s.%s]: %w", s.Name, f.Name, err)
+		}
+		if f.Count > 0 {
+			if count > 0 {
+				return nil, fmt.Errorf("field [%s.%s]: count of array type is duplicated", s.Name, f.Name)
+			}
+			count = f.Count
+		}
+		fd := &field{
+			name:    exportName(f.Name),
+			plcName: f.Name,
+			comment: f.Comment,
+			typ:     typ,
+			count:   count,
+		}
+		if fd.name == "" || names[fd.name] {
+			return nil, fmt.Errorf("field [%s.%s] name is empty or duplicated", s.Name, f.Name)
+		}
+		names[fd.name] = true
+
+		if f.Offset != "" {
+			match := offsetRegexp.FindStringSubmatch(strings.TrimSpace(f.Offset))
+			if match == nil {
+				return nil, fmt.Errorf("field [%s.%s]: offset [%s] is invalid", s.Name, f.Name, f.Offset)
+			}
+			byteOff, _ := strconv.Atoi(match[1])
+			bitOff, _ := strconv.Atoi(util.StrOrDefault(match[2], "0"))
+			if bitOff != 0 && (!typ.isBool() || count > 0) {
+				return nil, fmt.Errorf("field [%s.%s]: bit offset is only allowed for bool", s.Name, f.Name)
+			}
+			bit = byteOff*8 + bitOff
+		} else if !typ.isBool() || count > 0 {
+			// bool packs bits, byte and char are byte aligned, others are word aligned
+			if count == 0 && typ.size() == 1 {
+				bit = alignUp(bit, 8)
+			} else {
+				bit = alignUp(bit, 16)
+			}
+		}
+		fd.byteOff, fd.bitOff = bit/8, bit%8
+
+		switch {
+		case typ.isBool() && count == 0:
+			bit++
+		case typ.isBool():
+			bit = alignUp(bit+count, 16)
+		case count > 0:
+			bit = alignUp(bit+fd.size()*8, 16)
+		default:
+			bit += typ.size() * 8
+		}
+		def.fields = append(def.fields, fd)
+	}
+	def.size = alignUp(bit, 16) / 8
+	return def, nil
+}
+
+func (r *resolver) resolveFieldType(name string) (*fieldType, int, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	count := 0
+	if match := arrayTypeRegexp.FindStringSubmatch(upper); match != nil {
+		lo, _ := strconv.Atoi(match[1])
+		hi, _ := strconv.Atoi(match[2])
+		if hi < lo {
+			return nil, 0, fmt.Errorf("array bounds of [%s] are invalid", name)
+		}
+		count = hi - lo + 1
+		name = strings.TrimSpace(name[len(name)-len(match[3]):])
+		upper = match[3]
+	}
+	if t, ok := plcTypes[upper]; ok {
+		if t.tagOnly {
+			return nil, 0, fmt.Errorf("type [%s] is not allowed in data block", name)
+		}
+		return &fieldType{scalar: &t}, count, nil
+	}
+	if match := stringTypeRegexp.FindStringSubmatch(upper); match != nil {
+		length, _ := strconv.Atoi(util.StrOrDefault(match[2], strconv.Itoa(defaultStringLength)))
+		if length <= 0 || length > defaultStringLength && match[1] == "STRING" {
+			return nil, 0, fmt.Errorf("string length of [%s] is invalid", name)
+		}
+		return &fieldType{str: true, wide: match[1] == "WSTRING", strLen: length}, count, nil
+	}
+	def, err := r.resolveType(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &fieldType{udt: def}, count, nil
+}
+
+// LoadTags load tags of imported PLC tag table
+func LoadTags(name string) ([]core.Tag, error) {
+	tags, err := core.TagTableFromFile(name)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]core.Tag, 0, tags.Len())
+	for _, n := range tags.Names() {
+		tag, _ := tags.Get(n)
+		res = append(res, tag)
+	}
+	return res, nil
+}
+
+// exportName convert PLC identifier to exported go identifier, e.g. motor_speed -> MotorSpeed
+func exportName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range strings.Trim(name, "\"") {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	res := sb.String()
+	if res != "" && !unicode.IsLetter([]rune(res)[0]) {
+		res = "F" + res
+	}
+	return res
+}
+
+func alignUp(v, n int) int {
+	return (v + n - 1) / n * n
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// gs7-gen generate typed go structs and accessors for PLC data blocks.
+//
+// The input is a data block layout in json/yaml, or a PLC tag table exported from TIA Portal (csv/xlsx).
+//
+//	gs7-gen -in layout.yaml -out plc_gen.go -package plc
+//	gs7-gen -in PLCTags.xlsx -out tags_gen.go -package plc -name MachineTags
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	in := flag.String("in", "", "layout (.json/.yaml/.yml) or PLC tag table (.csv/.xlsx)")
+	out := flag.String("out", "", "output go file, default stdout")
+	pkg := flag.String("package", "", "go package name, default package of layout or plc")
+	name := flag.String("name", "Tags", "accessor type name of PLC tag table")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, err := generate(*in, *pkg, *name)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gs7-gen: %s\n", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		_, _ = os.Stdout.Write(src)
+		return
+	}
+	if err = os.WriteFile(*out, src, 0644); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "gs7-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(in string, pkg string, name string) ([]byte, error) {
+	g := newGenerator()
+	switch strings.ToLower(filepath.Ext(in)) {
+	case ".csv", ".xlsx":
+		tags, err := LoadTags(in)
+		if err != nil {
+			return nil, err
+		}
+		if err = g.GenerateTags(exportName(name), tags); err != nil {
+			return nil, err
+		}
+	default:
+		layout, err := LoadLayout(in)
+		if err != nil {
+			return nil, err
+		}
+		defs, err := layout.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		g.GenerateLayout(defs)
+		if pkg == "" {
+			pkg = layout.Package
+		}
+	}
+	if pkg == "" {
+		pkg = "plc"
+	}
+	return g.source(pkg, filepath.Base(in))
+}
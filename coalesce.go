@@ -0,0 +1,170 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"math"
+	"sort"
+)
+
+// DefaultReadCoalesceGap default maximum gap in bytes between addresses read as one range
+// a request item costs 12 bytes in the request and 4 bytes in the response, smaller gaps are cheaper to read
+const DefaultReadCoalesceGap = 8
+
+// readSlice position of a request item in the coalesced ranges
+type readSlice struct {
+	// offset byte offset in the range
+	offset int
+	// size bytes of the item, -1 for the whole range
+	size int
+	// bit bit address of bit items, -1 for others
+	bit int
+}
+
+// readRange range of bytes read at once for neighbouring items
+type readRange struct {
+	item *core.StandardRequestItem
+	// members indexes of the request items read by the range
+	members []int
+}
+
+// coalesceReads merge byte addressed items of the same area and data block into ranges,
+// when the gap between neighbours is not greater than gap and the range does not exceed maxSize bytes.
+// Timers, counters and items of other types are kept as own range.
+func coalesceReads(requests []common.RequestItem, gap int, maxSize int) ([]*readRange, []readSlice) {
+	slices := make([]readSlice, len(requests))
+	ranges := make([]*readRange, 0, len(requests))
+	merged := make([]int, 0, len(requests))
+	for i, request := range requests {
+		item := request.(*core.StandardRequestItem)
+		if !coalescable(item) {
+			slices[i] = readSlice{size: -1, bit: -1}
+			ranges = append(ranges, &readRange{item: item, members: []int{i}})
+			continue
+		}
+		merged = append(merged, i)
+	}
+	sort.SliceStable(merged, func(a, b int) bool {
+		x, y := requests[merged[a]].(*core.StandardRequestItem), requests[merged[b]].(*core.StandardRequestItem)
+		if x.Area != y.Area {
+			return x.Area < y.Area
+		}
+		if x.DbNumber != y.DbNumber {
+			return x.DbNumber < y.DbNumber
+		}
+		return x.ByteAddress < y.ByteAddress
+	})
+
+	var current *readRange
+	for _, i := range merged {
+		item := requests[i].(*core.StandardRequestItem)
+		start, end := item.ByteAddress, item.ByteAddress+itemSize(item)
+		if current != nil {
+			head := current.item
+			rangeEnd := head.ByteAddress + int(head.Count)
+			if head.Area == item.Area && head.DbNumber == item.DbNumber &&
+				start <= rangeEnd+gap && max(rangeEnd, end)-head.ByteAddress <= maxSize {
+				head.Count = uint16(max(rangeEnd, end) - head.ByteAddress)
+				current.members = append(current.members, i)
+				slices[i] = readSlice{offset: start - head.ByteAddress, size: end - start, bit: bitOf(item)}
+				continue
+			}
+		}
+		current = &readRange{
+			item:    core.NewStandardRequestItem(item.Area, int(item.DbNumber), common.PvtByte, start, 0, end-start),
+			members: []int{i},
+		}
+		slices[i] = readSlice{size: end - start, bit: bitOf(item)}
+		ranges = append(ranges, current)
+	}
+	return ranges, slices
+}
+
+// coalescable item addressed by byte offset, read as bytes of a range.
+// peripheral items are read alone, the gaps between them are often unconfigured i/o failing the whole range
+func coalescable(item *core.StandardRequestItem) bool {
+	if item.VariableType.DataVariableType() == common.DvtOctetString || item.Area == common.AtDirectPeripheralAccess {
+		return false
+	}
+	return item.VariableType == common.PvtBit || item.VariableType.Size() > 0
+}
+
+// itemSize bytes of the item in the range, a bit takes the byte of it
+func itemSize(item *core.StandardRequestItem) int {
+	if item.VariableType == common.PvtBit {
+		return 1
+	}
+	return int(item.VariableType.Size()) * int(item.Count)
+}
+
+func bitOf(item *core.StandardRequestItem) int {
+	if item.VariableType == common.PvtBit {
+		return item.BitAddress
+	}
+	return -1
+}
+
+// data of the item sliced from data of the range
+func (s readSlice) data(bs []byte) []byte {
+	if s.size < 0 {
+		return bs
+	}
+	if s.bit >= 0 {
+		return []byte{(bs[s.offset] >> s.bit) & 0x01}
+	}
+	return bs[s.offset : s.offset+s.size]
+}
+
+// coalesceWrites merge contiguous byte writes of the same area and data block into one item,
+// bits, timers and counters are kept as own item.
+// If ordered, only items following each other are merged, otherwise the items are sorted by address first.
+// owners indexes of the requests written by each merged item
+func coalesceWrites(requests []common.RequestItem, dataItems []common.ResponseItem, ordered bool) ([]common.RequestItem, []common.ResponseItem, [][]int) {
+	order := make([]int, len(requests))
+	for i := range order {
+		order[i] = i
+	}
+	if !ordered {
+		sort.SliceStable(order, func(a, b int) bool {
+			x, y := requests[order[a]].(*core.StandardRequestItem), requests[order[b]].(*core.StandardRequestItem)
+			if x.Area != y.Area {
+				return x.Area < y.Area
+			}
+			if x.DbNumber != y.DbNumber {
+				return x.DbNumber < y.DbNumber
+			}
+			return x.ByteAddress < y.ByteAddress
+		})
+	}
+
+	mergedRequests := make([]common.RequestItem, 0, len(requests))
+	mergedDataItems := make([]common.ResponseItem, 0, len(requests))
+	owners := make([][]int, 0, len(requests))
+	var last *core.StandardRequestItem
+	var lastData *core.DataItem
+	for _, i := range order {
+		item := requests[i].(*core.StandardRequestItem)
+		dataItem := dataItems[i].(*core.DataItem)
+		if item.VariableType == common.PvtByte && last != nil && last.VariableType == common.PvtByte &&
+			last.Area == item.Area && last.DbNumber == item.DbNumber &&
+			last.ByteAddress+int(last.Count) == item.ByteAddress && int(last.Count)+int(item.Count) <= math.MaxUint16 {
+			last.Count += item.Count
+			lastData.Data = append(lastData.Data, dataItem.Data...)
+			lastData.Count = uint16(len(lastData.Data))
+			owners[len(owners)-1] = append(owners[len(owners)-1], i)
+			continue
+		}
+		// copies, the merged items are extended
+		requestItem, mergedData := *item, *dataItem
+		mergedData.Data = append([]byte{}, dataItem.Data...)
+		last, lastData = &requestItem, &mergedData
+		mergedRequests = append(mergedRequests, last)
+		mergedDataItems = append(mergedDataItems, lastData)
+		owners = append(owners, []int{i})
+	}
+	return mergedRequests, mergedDataItems, owners
+}
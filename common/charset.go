@@ -0,0 +1,75 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package common
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Charset 字符编码，用于STRING、CHAR数组以及块信息中的作者、系列名称
+type Charset byte
+
+const (
+	// CsGBK GBK，默认编码
+	CsGBK Charset = 0x00
+	// CsUTF8 UTF-8
+	CsUTF8 Charset = 0x01
+	// CsWindows1252 Windows-1252
+	CsWindows1252 Charset = 0x02
+	// CsLatin1 ISO-8859-1
+	CsLatin1 Charset = 0x03
+	// CsShiftJIS Shift-JIS
+	CsShiftJIS Charset = 0x04
+)
+
+// Encoding x/text encoding of the charset
+func (c Charset) Encoding() encoding.Encoding {
+	switch c {
+	case CsUTF8:
+		return unicode.UTF8
+	case CsWindows1252:
+		return charmap.Windows1252
+	case CsLatin1:
+		return charmap.ISO8859_1
+	case CsShiftJIS:
+		return japanese.ShiftJIS
+	default:
+		return simplifiedchinese.GBK
+	}
+}
+
+// Decode decode bytes of the charset to utf-8 string
+func (c Charset) Decode(bs []byte) (string, error) {
+	res, err := c.Encoding().NewDecoder().Bytes(bs)
+	if err != nil {
+		return "", err
+	}
+	return string(res), nil
+}
+
+// Encode encode utf-8 string to bytes of the charset
+// characters not in the charset are an error
+func (c Charset) Encode(s string) ([]byte, error) {
+	return c.Encoding().NewEncoder().Bytes([]byte(s))
+}
+
+func (c Charset) String() string {
+	switch c {
+	case CsUTF8:
+		return "UTF-8"
+	case CsWindows1252:
+		return "Windows-1252"
+	case CsLatin1:
+		return "ISO-8859-1"
+	case CsShiftJIS:
+		return "Shift-JIS"
+	default:
+		return "GBK"
+	}
+}
@@ -120,10 +120,40 @@ const (
 	PvtDateTime = 0x0F
 	// PvtDTL dtl
 	PvtDTL = 0x10
+	// PvtSInt SINT，以下为S7-1200/1500数据类型，协议中没有对应的传输类型，按字节读写
+	PvtSInt = 0x30
+	// PvtUSInt USINT
+	PvtUSInt = 0x31
+	// PvtUInt UINT
+	PvtUInt = 0x32
+	// PvtUDInt UDINT
+	PvtUDInt = 0x33
+	// PvtLInt LINT
+	PvtLInt = 0x34
+	// PvtULInt ULINT
+	PvtULInt = 0x35
+	// PvtLWord 长字
+	PvtLWord = 0x36
+	// PvtLReal 长浮点
+	PvtLReal = 0x37
+	// PvtLTime 长时间
+	PvtLTime = 0x38
+	// PvtLTimeOfDay LTOD
+	PvtLTimeOfDay = 0x39
+	// PvtLDT 长日期和时间，DATE_AND_LTIME
+	PvtLDT = 0x3A
+	// PvtWChar 宽字符
+	PvtWChar = 0x3B
 	// PvtCounter 计数器
 	PvtCounter = 0x1C
 	// PvtTimer 定时器
 	PvtTimer = 0x1D
+	// PvtIecCounter Iec计数器（200系列）
+	PvtIecCounter = 0x1E
+	// PvtIecTimer Iec定时器（200系列）
+	PvtIecTimer = 0x1F
+	// PvtHsCounter 高速计数器（200系列）
+	PvtHsCounter = 0x20
 	// PvtString 字符串
 	PvtString ParamVariableType = 0x00
 	// PvtWString 字符串
@@ -161,6 +191,8 @@ const (
 	AtIecCounters = 0x1E
 	// AtIecTimers Iec定时器（200系列）
 	AtIecTimers = 0x1F
+	// AtHsCounters 高速计数器（200系列）
+	AtHsCounters = 0x20
 
 	// PtConnectRequest 连接请求
 	PtConnectRequest PduType = 0xE0
@@ -569,7 +601,7 @@ func (w ParamVariableType) DataVariableType() DataVariableType {
 	switch w {
 	case PvtBit:
 		return DvtBit
-	case PvtCounter, PvtTimer:
+	case PvtCounter, PvtTimer, PvtIecCounter, PvtIecTimer, PvtHsCounter:
 		return DvtOctetString
 	default:
 		return DvtByteWordDword
@@ -606,10 +638,22 @@ func (w ParamVariableType) Size() uint16 {
 		return 8
 	case PvtDTL:
 		return 12
+	case PvtSInt, PvtUSInt:
+		return 1
+	case PvtUInt, PvtWChar:
+		return 2
+	case PvtUDInt:
+		return 4
+	case PvtLInt, PvtULInt, PvtLWord, PvtLReal, PvtLTime, PvtLTimeOfDay, PvtLDT:
+		return 8
 	case PvtCounter:
 		return 2
 	case PvtTimer:
 		return 2
+	case PvtIecCounter, PvtIecTimer:
+		return 2
+	case PvtHsCounter:
+		return 4
 	default:
 		return 0
 	}
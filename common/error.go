@@ -6,103 +6,268 @@ package common
 
 import (
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"github.com/spf13/cast"
 )
 
+// ErrorCode error code of gs7, each code is also a sentinel error matching the errors of the code
+//
+//	errors.Is(err, common.ErrTcpRequestTimeout)
+//
+// The codes were untyped integer constants before, int variables holding a code convert it, e.g. ErrorCode(code)
 type ErrorCode uint16
 
 const (
-	ErrOk                       = 0x0000
-	ErrCommon                   = 0x0001
-	ErrModelFromBytes           = 0x0002
-	ErrTypeNotResolved          = 0x0003
-	ErrVariableTypeUnrecognized = 0x0004
-	ErrPasswordLengthInvalid    = 0x0005
+	ErrOk                       ErrorCode = 0x0000
+	ErrCommon                   ErrorCode = 0x0001
+	ErrModelFromBytes           ErrorCode = 0x0002
+	ErrTypeNotResolved          ErrorCode = 0x0003
+	ErrVariableTypeUnrecognized ErrorCode = 0x0004
+	ErrPasswordLengthInvalid    ErrorCode = 0x0005
 
-	ErrCliConnectionNil          = 0x0101
-	ErrCliConnectionConnecting   = 0x0102
-	ErrCliRequestDataEmpty       = 0x0103
-	ErrCliRequestDataDifferent   = 0x0104
-	ErrCliUploadFailed           = 0x0105
-	ErrCliResponseInvalid        = 0x0106
-	ErrCliResponseExceptional    = 0x0107
-	ErrCliPduReferenceMismatch   = 0x0108
-	ErrCliResponseLengthMismatch = 0x0109
-	ErrCliConnectionInactive     = 0x0110
-	ErrCliRequestItemInvalid     = 0x0111
-	ErrCliSzlPartsInvalid        = 0x0112
-	ErrCliConnectionNotNil       = 0x0113
+	ErrCliConnectionNil          ErrorCode = 0x0101
+	ErrCliConnectionConnecting   ErrorCode = 0x0102
+	ErrCliRequestDataEmpty       ErrorCode = 0x0103
+	ErrCliRequestDataDifferent   ErrorCode = 0x0104
+	ErrCliUploadFailed           ErrorCode = 0x0105
+	ErrCliResponseInvalid        ErrorCode = 0x0106
+	ErrCliResponseExceptional    ErrorCode = 0x0107
+	ErrCliPduReferenceMismatch   ErrorCode = 0x0108
+	ErrCliResponseLengthMismatch ErrorCode = 0x0109
+	ErrCliConnectionInactive     ErrorCode = 0x0110
+	ErrCliRequestItemInvalid     ErrorCode = 0x0111
+	ErrCliSzlPartsInvalid        ErrorCode = 0x0112
+	ErrCliConnectionNotNil       ErrorCode = 0x0113
+	ErrCliRequestDataInvalid     ErrorCode = 0x0114
+	ErrCliValueInvalid           ErrorCode = 0x0115
+	ErrCliValueTypeMismatch      ErrorCode = 0x0116
+	ErrCliStringTooLong          ErrorCode = 0x0117
+	ErrCliWriteAborted           ErrorCode = 0x0118
+	ErrCliRollbackJournal        ErrorCode = 0x0119
+	ErrCliWriteRolledBack        ErrorCode = 0x011A
+	ErrCliHeartbeatMissed        ErrorCode = 0x011B
 
-	ErrTcpRequestProcessing   = 0x1001
-	ErrTcpRequestTimeout      = 0x1002
-	ErrTcpRequestRejected     = 0x1003
-	ErrTcpConnect             = 0x1004
-	ErrTcpResponseEmpty       = 0x1005
-	ErrTcpConnectWithAttempts = 0x1006
+	ErrTcpRequestProcessing   ErrorCode = 0x1001
+	ErrTcpRequestTimeout      ErrorCode = 0x1002
+	ErrTcpRequestRejected     ErrorCode = 0x1003
+	ErrTcpConnect             ErrorCode = 0x1004
+	ErrTcpResponseEmpty       ErrorCode = 0x1005
+	ErrTcpConnectWithAttempts ErrorCode = 0x1006
+	ErrTcpProxyConnect        ErrorCode = 0x1007
 
-	ErrAddressEmpty   = 0x1101
-	ErrAddressInvalid = 0x1102
+	ErrAddressEmpty         ErrorCode = 0x1101
+	ErrAddressInvalid       ErrorCode = 0x1102
+	ErrTagNotFound          ErrorCode = 0x1103
+	ErrTagTableInvalid      ErrorCode = 0x1104
+	ErrAddressPeripheralBit ErrorCode = 0x1105
 )
 
-func ErrorWithCode(code int, params ...any) (err error) {
+func (c ErrorCode) Error() string {
+	return fmt.Sprintf("gs7 error code [0x%04X]", uint16(c))
+}
+
+// Error error of gs7 with the error code, the error class and code of plc and the related address
+// inspect it with errors.As, or match the code with errors.Is
+type Error struct {
+	// Code error code of gs7
+	Code ErrorCode
+	// ErrorClass error class of the AckHeader or UserdataAckParameter of plc
+	ErrorClass byte
+	// S7ErrorCode error code of the AckHeader or UserdataAckParameter of plc, the error class in the high byte
+	S7ErrorCode uint16
+	// Address related address
+	Address string
+	// Err cause of the error, e.g. the error of the tcp connection
+	Err error
+	msg string
+}
+
+func (e *Error) Error() string {
+	if e.Address != "" {
+		return fmt.Sprintf("%s: [%s]", e.msg, e.Address)
+	}
+	return e.msg
+}
+
+// Is match the error code, e.g. errors.Is(err, common.ErrTcpRequestTimeout)
+func (e *Error) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && code == e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func ErrorWithCode(code ErrorCode, params ...any) error {
+	msg, ok := errorMessage(code, params...)
+	if !ok {
+		return nil
+	}
+	e := &Error{Code: code, msg: msg}
+	for _, param := range params {
+		if err, ok := param.(error); ok {
+			e.Err = err
+		}
+	}
+	return e
+}
+
+// S7Error error of the error class and code of an ack of plc
+func S7Error(errorClass byte, errorCode []byte) error {
+	e := ErrorWithCode(ErrCliResponseExceptional,
+		ErrorClassDescOrDefault(errorClass, "UnKnown"),
+		ErrorCodeDescOrDefault(errorCode, "UnKnown")).(*Error)
+	e.ErrorClass = errorClass
+	e.S7ErrorCode = binary.BigEndian.Uint16(errorCode)
+	return e
+}
+
+// WithAddress the error with the related address, errors other than Error and ReturnCodeError are returned as is
+func WithAddress(err error, address string) error {
+	switch e := err.(type) {
+	case *Error:
+		c := *e
+		c.Address = address
+		return &c
+	case ReturnCodeError:
+		e.Address = address
+		return e
+	default:
+		return err
+	}
+}
+
+func errorMessage(code ErrorCode, params ...any) (string, bool) {
 	switch code {
 	case ErrCommon:
-		return fmt.Errorf(cast.ToString(params[0]), params[1:]...)
+		return fmt.Sprintf(cast.ToString(params[0]), params[1:]...), true
 	case ErrModelFromBytes:
-		return fmt.Errorf("[%s] format must gte [%d] bytes", params...)
+		return fmt.Sprintf("[%s] format must gte [%d] bytes", params...), true
 	case ErrTypeNotResolved:
-		return fmt.Errorf("cannot be resolved %s: %d", params...)
+		return fmt.Sprintf("cannot be resolved %s: %d", params...), true
 	case ErrVariableTypeUnrecognized:
-		return fmt.Errorf("variable type is unrecognized for: [%d]", params...)
+		return fmt.Sprintf("variable type is unrecognized for: [%d]", params...), true
 	case ErrPasswordLengthInvalid:
-		return fmt.Errorf("password length must lte %d", params...)
+		return fmt.Sprintf("password length must lte %d", params...), true
 	case ErrCliConnectionNil:
-		return fmt.Errorf("connection for [%s:%d] is nil", params...)
+		return fmt.Sprintf("connection for [%s:%d] is nil", params...), true
 	case ErrCliConnectionConnecting:
-		return fmt.Errorf("connection for [%s:%d] is connecting", params...)
+		return fmt.Sprintf("connection for [%s:%d] is connecting", params...), true
 	case ErrCliRequestDataEmpty:
-		return errors.New("request data is empty")
+		return "request data is empty", true
 	case ErrCliRequestDataDifferent:
-		return errors.New("request data length is different from addresses")
+		return "request data length is different from addresses", true
 	case ErrCliUploadFailed:
-		return errors.New("upload failed from response status")
+		return "upload failed from response status", true
 	case ErrCliResponseInvalid:
-		return errors.New("invalid response")
+		return "invalid response", true
 	case ErrCliResponseExceptional:
-		return fmt.Errorf("response exceptional, class:[%s], reason: [%s]", params...)
+		return fmt.Sprintf("response exceptional, class:[%s], reason: [%s]", params...), true
 	case ErrCliPduReferenceMismatch:
-		return errors.New("pdu reference mismatch")
+		return "pdu reference mismatch", true
 	case ErrCliResponseLengthMismatch:
-		return errors.New("response data does not match the length of request data")
+		return "response data does not match the length of request data", true
 	case ErrCliConnectionInactive:
-		return fmt.Errorf("connection for [%s:%d] is inactive", params...)
+		return fmt.Sprintf("connection for [%s:%d] is inactive", params...), true
 	case ErrCliRequestItemInvalid:
-		return errors.New("request item invalid")
+		return "request item invalid", true
 	case ErrCliSzlPartsInvalid:
-		return errors.New("szl parts invalid")
+		return "szl parts invalid", true
 	case ErrCliConnectionNotNil:
-		return fmt.Errorf("connection for [%s:%d] is not nil", params...)
+		return fmt.Sprintf("connection for [%s:%d] is not nil", params...), true
+	case ErrCliRequestDataInvalid:
+		return fmt.Sprintf("request data for [%s] must be [%d] bytes", params...), true
+	case ErrCliValueInvalid:
+		return fmt.Sprintf("value [%v] can not be written to [%s]: %s", params...), true
+	case ErrCliValueTypeMismatch:
+		return fmt.Sprintf("value of [%s] is [%T], can not be read as [%s]", params...), true
+	case ErrCliStringTooLong:
+		return fmt.Sprintf("string of [%s] has length [%d], exceeds the max length [%d]", params...), true
+	case ErrCliWriteAborted:
+		return fmt.Sprintf("write of [%s] aborted after a failed write", params...), true
+	case ErrCliRollbackJournal:
+		return fmt.Sprintf("original data for rollback can not be read, nothing is written: %s", params...), true
+	case ErrCliWriteRolledBack:
+		return fmt.Sprintf("write of [%s] rolled back after a failed write", params...), true
+	case ErrCliHeartbeatMissed:
+		return fmt.Sprintf("connection for [%s] is dead after [%d] missed heartbeats", params...), true
 	case ErrTcpRequestProcessing:
-		return fmt.Errorf("tcp client request for [%d] is already processing", params...)
+		return fmt.Sprintf("tcp client request for [%d] is already processing", params...), true
 	case ErrTcpRequestTimeout:
-		return errors.New("request timeout")
+		return "request timeout", true
 	case ErrTcpConnect:
-		return fmt.Errorf("tcp connection with error: %s", params...)
+		return fmt.Sprintf("tcp connection with error: %s", params...), true
 	case ErrTcpResponseEmpty:
-		return errors.New("empty response")
+		return "empty response", true
 	case ErrTcpConnectWithAttempts:
-		return fmt.Errorf("failed to connect to [%s] after [%d] attempts", params...)
+		return fmt.Sprintf("failed to connect to [%s] after [%d] attempts: %v", params...), true
+	case ErrTcpProxyConnect:
+		return fmt.Sprintf("proxy [%s] refused to connect to [%s]: %s", params...), true
 	case ErrAddressEmpty:
-		return errors.New("request address is empty")
+		return "request address is empty", true
 	case ErrAddressInvalid:
-		return errors.New("request address is invalid")
+		return "request address is invalid", true
+	case ErrAddressPeripheralBit:
+		return fmt.Sprintf("peripheral address [%s] can not be accessed by bit, access its byte, e.g. PIB0", params...), true
+	case ErrTagNotFound:
+		return fmt.Sprintf("tag [%s] is not found in tag table", params...), true
+	case ErrTagTableInvalid:
+		return fmt.Sprintf("tag table is invalid: %s", params...), true
 	default:
-		return
+		return "", false
 	}
 }
 
+// ReturnCodeError item of read/write request failed with the return code of plc
+// matches ErrCliResponseExceptional with errors.Is
+type ReturnCodeError struct {
+	ReturnCode ReturnCode
+	// Address address of the item
+	Address string
+}
+
+func (e ReturnCodeError) Error() string {
+	msg := fmt.Sprintf("response exceptional, class:[UnKnown], reason: [%s]", ReturnCodeDescOrDefault(e.ReturnCode, "UnKnown"))
+	if e.Address != "" {
+		return fmt.Sprintf("%s: [%s]", msg, e.Address)
+	}
+	return msg
+}
+
+func (e ReturnCodeError) Is(target error) bool {
+	return target == ErrCliResponseExceptional
+}
+
+// VerifyError data read back after write differs from the written data
+type VerifyError struct {
+	Address  string
+	Expected []byte
+	Actual   []byte
+}
+
+func (e VerifyError) Error() string {
+	return fmt.Sprintf("verify of [%s] failed, expected [% x], actual [% x]", e.Address, e.Expected, e.Actual)
+}
+
+// RollbackError write failed and the original data was written back
+type RollbackError struct {
+	// Cause error of the failed write
+	Cause error
+	// Restored addresses written back with the original data
+	Restored []string
+	// NotRestored addresses possibly written but failed to be written back
+	NotRestored []string
+}
+
+func (e RollbackError) Error() string {
+	return fmt.Sprintf("write failed and rolled back, restored %v, not restored %v: %s", e.Restored, e.NotRestored, e.Cause)
+}
+
+func (e RollbackError) Unwrap() error {
+	return e.Cause
+}
+
 var ErrorClassDescMap = map[byte]string{
 	0x00: "没有错误",
 	0x81: "应用关系",
@@ -122,6 +287,18 @@ func ErrorClassDescOrDefault(errorClass byte, defaultVal string) string {
 	return defaultVal
 }
 
+// IsResourceError the error class and code of an ack report missing resources of plc
+func IsResourceError(errorClass byte, errorCode uint16) bool {
+	if errorClass == 0x83 {
+		return true
+	}
+	switch errorCode {
+	case 0x011A, 0x011B, 0x0141, 0x8302, 0x8304:
+		return true
+	}
+	return errorCode >= 0xD061 && errorCode <= 0xD067
+}
+
 var ErrorCodeDescMap = map[uint16]string{
 	0x0000: "没有错误",
 	0x0110: "块号无效",
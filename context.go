@@ -19,13 +19,23 @@ type StandardRequestContext struct {
 	Request   *core.PDU
 	Response  chan *core.PDU
 	Error     chan error
+	// Callback called with the response or the error instead of the channels if set, must not block
+	Callback func(res *core.PDU, err error)
 }
 
 func (s *StandardRequestContext) PutError(err error) {
+	if s.Callback != nil {
+		s.Callback(nil, err)
+		return
+	}
 	s.Error <- err
 }
 
 func (s *StandardRequestContext) PutResponse(data *core.PDU) {
+	if s.Callback != nil {
+		s.Callback(data, nil)
+		return
+	}
 	s.Response <- data
 }
 
@@ -52,13 +62,23 @@ type ConnectRequestContext struct {
 	Request  *core.PDU
 	Response chan *core.PDU
 	Error    chan error
+	// Callback called with the response or the error instead of the channels if set, must not block
+	Callback func(res *core.PDU, err error)
 }
 
 func (c *ConnectRequestContext) PutError(err error) {
+	if c.Callback != nil {
+		c.Callback(nil, err)
+		return
+	}
 	c.Error <- err
 }
 
 func (c *ConnectRequestContext) PutResponse(data *core.PDU) {
+	if c.Callback != nil {
+		c.Callback(data, nil)
+		return
+	}
 	c.Response <- data
 }
 
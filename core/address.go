@@ -6,6 +6,7 @@ package core
 
 import (
 	"github.com/shiyuecamus/gs7/common"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,6 +20,126 @@ func ParseAddress(address string) (requestItem common.RequestItem, err error) {
 	address = strings.ToUpper(address)
 	address = strings.Replace(address, " ", "", -1)
 
+	dataType := ""
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		address, dataType = address[:i], address[i+1:]
+	}
+	// the array count may follow the type suffix, e.g. DB1.DBD8:REAL[4], strings declare their length there
+	if match := arrayAddressRegexp.FindStringSubmatch(dataType); match != nil {
+		variableType, ok := dataTypes[normalizeDataType(match[1])]
+		if ok && variableType != common.PvtString && variableType != common.PvtWString {
+			if arrayAddressRegexp.MatchString(address) {
+				err = common.ErrorWithCode(common.ErrAddressInvalid)
+				return
+			}
+			address, dataType = address+"["+match[2]+"]", match[1]
+		}
+	}
+	count := 0
+	if match := arrayAddressRegexp.FindStringSubmatch(address); match != nil {
+		address = match[1]
+		if count, err = strconv.Atoi(match[2]); err != nil || count < 1 {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+	}
+	logical := strings.HasPrefix(address, "%")
+	address = strings.TrimPrefix(address, "%")
+	if address == "" {
+		err = common.ErrorWithCode(common.ErrAddressInvalid)
+		return
+	}
+	// siemens absolute addresses (I0.1, %MD10, MW10, PIW256, EW2, AD4, AIW0) use the size letter,
+	// DB and DI addresses and the other types of gs7 notation (MI10, MR4, VW2) are parsed by the gs7 notation.
+	// without % the D of I, Q and M keeps its gs7 meaning DATE, e.g. MD10, %MD10 is a DWord
+	switch {
+	case strings.HasPrefix(address, "DB"), strings.HasPrefix(address, "DI"):
+		requestItem, err = parseAbsoluteAddress(address)
+	case logical || logicalAddressRegexp.MatchString(address) && !dateAddressRegexp.MatchString(address):
+		requestItem, err = parseLogicalAddress(address)
+	default:
+		requestItem, err = parseAbsoluteAddress(address)
+	}
+	if err != nil {
+		return
+	}
+	item := requestItem.(*StandardRequestItem)
+	if dataType != "" {
+		variableType, ok := dataTypes[normalizeDataType(dataType)]
+		// only strings declare a length in the type suffix, e.g. DB1.DBB10:STRING[20]
+		if !ok || strings.Contains(dataType, "[") && variableType != common.PvtString && variableType != common.PvtWString {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		if err = setDataType(item, variableType); err != nil {
+			return
+		}
+	}
+	switch {
+	case item.VariableType == common.PvtString || item.VariableType == common.PvtWString:
+		// strings declare the max length instead of element count, e.g. DB1.S10[254], DB1.DBB10:STRING[20]
+		length := dataTypeLength(dataType)
+		if count > 0 && length > 0 {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		err = setMaxLength(item, max(count, length))
+	case count > 0:
+		err = setArrayCount(item, count)
+	}
+	return
+}
+
+// setMaxLength declared max length of STRING(254 at most) or WSTRING(16382 at most), 0 leaves it unknown
+func setMaxLength(item *StandardRequestItem, length int) error {
+	limit := 254
+	if item.VariableType == common.PvtWString {
+		limit = 16382
+	}
+	if length > limit {
+		return common.ErrorWithCode(common.ErrAddressInvalid)
+	}
+	item.MaxLength = uint16(length)
+	return nil
+}
+
+// setDataType override the variable type given by the address, e.g. DB1.DBD8:REAL
+// the size must be the same, except byte addresses which give the start of the value
+func setDataType(item *StandardRequestItem, variableType common.ParamVariableType) error {
+	switch {
+	case item.VariableType == variableType:
+	case item.VariableType == common.PvtBit || variableType == common.PvtBit:
+		return common.ErrorWithCode(common.ErrAddressInvalid)
+	case item.VariableType == common.PvtByte:
+	case variableType == common.PvtString || variableType == common.PvtWString ||
+		variableType.Size() != item.VariableType.Size():
+		return common.ErrorWithCode(common.ErrAddressInvalid)
+	}
+	item.VariableType = variableType
+	return nil
+}
+
+// setArrayCount make the item an array of count elements, e.g. DB1.REAL0[100], MW10[8], DB2.X0.0[16]
+// strings, timers and counters can not be read as array
+func setArrayCount(item *StandardRequestItem, count int) error {
+	switch item.VariableType {
+	case common.PvtString, common.PvtWString, common.PvtTimer, common.PvtCounter, common.PvtHsCounter:
+		return common.ErrorWithCode(common.ErrAddressInvalid)
+	case common.PvtBit:
+		if (item.BitAddress+count+7)/8 > math.MaxUint16 {
+			return common.ErrorWithCode(common.ErrAddressInvalid)
+		}
+	default:
+		if count*int(item.VariableType.Size()) > math.MaxUint16 {
+			return common.ErrorWithCode(common.ErrAddressInvalid)
+		}
+	}
+	item.Count = uint16(count)
+	item.Array = true
+	return nil
+}
+
+func parseAbsoluteAddress(address string) (requestItem common.RequestItem, err error) {
 	split := strings.Split(address, ".")
 	var (
 		area                              common.AreaType
@@ -46,38 +167,132 @@ func ParseAddress(address string) (requestItem common.RequestItem, err error) {
 	return
 }
 
+// parseLogicalAddress parse siemens absolute address (without the leading %)
+// as used by TIA tag tables, e.g. I0.3, IB0, MW10, %QD4, T5, C3, PIW256, PQW256, L0.1, LW2,
+// S7-200 SM0.1, SMB0, AIW0, AQW2, HC0,
+// german mnemonics E(input) and A(output) are accepted, e.g. E0.0, AW2, PEW256.
+// the size letter decides the variable type: X(or none)=Bit, B=Byte, W=Word, D=DWord
+func parseLogicalAddress(address string) (requestItem common.RequestItem, err error) {
+	match := logicalAddressRegexp.FindStringSubmatch(address)
+	if match == nil {
+		err = common.ErrorWithCode(common.ErrAddressInvalid)
+		return
+	}
+	peripheral, areaLetter, size, bit := match[1] != "", match[2], match[3], match[5]
+	output := areaLetter == "Q" || areaLetter == "A"
+	switch areaLetter {
+	case "E":
+		areaLetter = "I"
+	case "A":
+		areaLetter = "Q"
+	}
+	area, _, ok := parseAreaPrefix(areaLetter)
+	if !ok {
+		err = common.ErrorWithCode(common.ErrAddressInvalid)
+		return
+	}
+	byteAddress, _ := strconv.Atoi(match[4])
+	var variableType common.ParamVariableType
+	switch {
+	case peripheral:
+		// peripheral inputs and outputs are accessed by bytes, words and double words only
+		if area != common.AtInputs && area != common.AtOutputs {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		if size == "" || size == "X" {
+			err = common.ErrorWithCode(common.ErrAddressPeripheralBit, address)
+			return
+		}
+		area = common.AtDirectPeripheralAccess
+	case area == common.AtTimers, area == common.AtCounters, area == common.AtHsCounters:
+		if size != "" || bit != "" {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		if variableType, err = parseVariableType([]string{areaLetter}); err != nil {
+			return
+		}
+		requestItem = NewStandardRequestItem(area, 0, variableType, byteAddress, 0, 1)
+		return
+	case area == common.AtAnalogInputs, area == common.AtAnalogOutputs:
+		// analog values have no bits
+		if size == "" || size == "X" {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+	}
+	switch size {
+	case "", "X":
+		if bit == "" {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		variableType = common.PvtBit
+	case "B":
+		variableType = common.PvtByte
+	case "W":
+		variableType = common.PvtWord
+	case "D":
+		variableType = common.PvtDWord
+	}
+	bitAddress := 0
+	if bit != "" {
+		if variableType != common.PvtBit {
+			err = common.ErrorWithCode(common.ErrAddressInvalid)
+			return
+		}
+		bitAddress, _ = strconv.Atoi(bit)
+	}
+	item := NewStandardRequestItem(area, 0, variableType, byteAddress, bitAddress, 1)
+	item.PeripheralOutput = peripheral && output
+	requestItem = item
+	return
+}
+
 func parseVariableType(split []string) (variableType common.ParamVariableType, err error) {
-	switch one := split[0][:1]; one {
-	case "T":
+	area, prefix, ok := parseAreaPrefix(split[0])
+	if !ok {
+		err = common.ErrorWithCode(common.ErrAddressInvalid)
+		return
+	}
+	switch area {
+	case common.AtTimers:
 		variableType = common.PvtTimer
 		return
-	case "C":
+	case common.AtCounters:
 		variableType = common.PvtCounter
 		return
-	case "D":
+	case common.AtHsCounters:
+		variableType = common.PvtHsCounter
+		return
+	}
+	switch prefix {
+	case "DB", "DI":
 		if len(split) < 2 {
 			err = common.ErrorWithCode(common.ErrAddressInvalid)
 			return
 		}
-		return extractVariableType(split[1], true)
+		return extractVariableType(split[1])
 	default:
-		return extractVariableType(split[0], false)
+		return extractVariableType(split[0][len(prefix):])
 	}
 }
 
+// parseBitAddress bit of the byte, 0 to 7
 func parseBitAddress(split []string, variableType common.ParamVariableType) (int, error) {
-	switch one := split[0][:1]; one {
-	case "D":
-		if len(split) == 3 && variableType == common.PvtBit {
-			return extractNumber(split[2])
-		}
-		return 0, nil
-	default:
-		if len(split) == 2 && variableType == common.PvtBit {
-			return extractNumber(split[1])
-		}
+	index := 1
+	if split[0][:1] == "D" {
+		index = 2
+	}
+	if len(split) != index+1 || variableType != common.PvtBit {
 		return 0, nil
 	}
+	bit, err := extractNumber(split[index])
+	if err != nil || bit > 7 {
+		return 0, common.ErrorWithCode(common.ErrAddressInvalid)
+	}
+	return bit, nil
 }
 
 func parseByteAddress(split []string) (int, error) {
@@ -88,7 +303,7 @@ func parseByteAddress(split []string) (int, error) {
 		}
 		return 0, common.ErrorWithCode(common.ErrAddressInvalid)
 	default:
-		return 0, nil
+		return extractNumber(split[0])
 	}
 }
 
@@ -104,53 +319,74 @@ func parseDbNumber(split []string) (int, error) {
 }
 
 func parseArea(split []string) (common.AreaType, error) {
-	switch one := split[0][:1]; one {
-	case "I":
-		return common.AtInputs, nil
-	case "Q":
-		return common.AtOutputs, nil
-	case "M":
-		return common.AtFlags, nil
-	case "V", "D":
-		return common.AtDataBlocks, nil
-	case "T":
-		return common.AtTimers, nil
-	case "C":
-		return common.AtCounters, nil
-	default:
+	area, _, ok := parseAreaPrefix(split[0])
+	if !ok {
 		return 0, common.ErrorWithCode(common.ErrAddressInvalid)
 	}
+	return area, nil
 }
 
-func extractVariableType(src string, isDb bool) (variableType common.ParamVariableType, err error) {
+// parseAreaPrefix area of the address and the letters giving it, two letter areas go first
+func parseAreaPrefix(address string) (common.AreaType, string, bool) {
+	for _, a := range areaPrefixes {
+		if strings.HasPrefix(address, a.prefix) {
+			return a.area, a.prefix, true
+		}
+	}
+	return 0, "", false
+}
+
+var areaPrefixes = []struct {
+	prefix string
+	area   common.AreaType
+}{
+	{"DB", common.AtDataBlocks},
+	{"DI", common.AtInstanceDataBlocks},
+	{"SM", common.AtSystemFlag},
+	{"AI", common.AtAnalogInputs},
+	{"AQ", common.AtAnalogOutputs},
+	{"HC", common.AtHsCounters},
+	{"I", common.AtInputs},
+	{"Q", common.AtOutputs},
+	{"M", common.AtFlags},
+	{"V", common.AtDataBlocks},
+	{"L", common.AtLocalData},
+	{"T", common.AtTimers},
+	{"C", common.AtCounters},
+}
+
+var arrayAddressRegexp = regexp.MustCompile(`^(.+)\[(\d+)]$`)
+
+var logicalAddressRegexp = regexp.MustCompile(`^(P?)(SM|AI|AQ|HC|[IQMTCEAL])([XBWD]?)(\d+)(?:\.([0-7]))?$`)
+
+// dateAddressRegexp DATE of I, Q and M in gs7 notation, e.g. MD10, the same as DWord in siemens notation
+var dateAddressRegexp = regexp.MustCompile(`^[IQM]D\d+$`)
+
+// extractVariableType variable type of the address without area, e.g. R of MR10, DBX of DB1.DBX0.0
+func extractVariableType(src string) (variableType common.ParamVariableType, err error) {
 	re, err := regexp.Compile("\\D")
 	if err != nil {
 		return 0, err
 	}
 	all := re.FindAllString(src, -1)
 	t := strings.Join(all, "")
-	if !isDb {
-		if len(t) < 2 {
-			return 0, common.ErrorWithCode(common.ErrAddressInvalid)
-		}
-		t = t[1:]
-	} else if len(t) < 1 {
+	if len(t) < 1 {
 		return 0, common.ErrorWithCode(common.ErrAddressInvalid)
 	}
 	switch t {
-	case "X", "BIT":
+	case "X", "BIT", "DBX", "DIX":
 		variableType = common.PvtBit
 		return
-	case "B", "BYTE":
+	case "B", "BYTE", "DBB", "DIB":
 		variableType = common.PvtByte
 		return
 	case "C", "CHAR":
 		variableType = common.PvtChar
 		return
-	case "DW", "DWORD":
+	case "DW", "DWORD", "DBD", "DID":
 		variableType = common.PvtDWord
 		return
-	case "W", "WORD":
+	case "W", "WORD", "DBW", "DIW":
 		variableType = common.PvtWord
 		return
 	case "DI", "DINT":
@@ -186,6 +422,42 @@ func extractVariableType(src string, isDb bool) (variableType common.ParamVariab
 	case "WS", "WSTRING":
 		variableType = common.PvtWString
 		return
+	case "SI", "SINT":
+		variableType = common.PvtSInt
+		return
+	case "USI", "USINT":
+		variableType = common.PvtUSInt
+		return
+	case "UI", "UINT":
+		variableType = common.PvtUInt
+		return
+	case "UDI", "UDINT":
+		variableType = common.PvtUDInt
+		return
+	case "LI", "LINT":
+		variableType = common.PvtLInt
+		return
+	case "ULI", "ULINT":
+		variableType = common.PvtULInt
+		return
+	case "LW", "LWORD":
+		variableType = common.PvtLWord
+		return
+	case "LR", "LREAL":
+		variableType = common.PvtLReal
+		return
+	case "LT", "LTIME":
+		variableType = common.PvtLTime
+		return
+	case "LTOD", "LTIMEOFDAY":
+		variableType = common.PvtLTimeOfDay
+		return
+	case "LDT", "LDATETIME":
+		variableType = common.PvtLDT
+		return
+	case "WC", "WCHAR":
+		variableType = common.PvtWChar
+		return
 	default:
 		err = common.ErrorWithCode(common.ErrAddressInvalid)
 	}
@@ -197,6 +469,154 @@ func extractNumber(src string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	number := re.ReplaceAllString(src, "")
-	return strconv.Atoi(strings.TrimSpace(number))
+	number, err := strconv.Atoi(re.ReplaceAllString(src, ""))
+	if err != nil {
+		return 0, common.ErrorWithCode(common.ErrAddressInvalid)
+	}
+	return number, nil
+}
+
+// dataTypes PLC data types of tag tables and explicit type suffix of address
+var dataTypes = map[string]common.ParamVariableType{
+	"BOOL":           common.PvtBit,
+	"BYTE":           common.PvtByte,
+	"CHAR":           common.PvtChar,
+	"WORD":           common.PvtWord,
+	"INT":            common.PvtInt,
+	"DWORD":          common.PvtDWord,
+	"DINT":           common.PvtDInt,
+	"REAL":           common.PvtReal,
+	"TIME":           common.PvtTime,
+	"DATE":           common.PvtDate,
+	"TIME_OF_DAY":    common.PvtTimeOfDay,
+	"TOD":            common.PvtTimeOfDay,
+	"S5TIME":         common.PvtS5Time,
+	"DATE_AND_TIME":  common.PvtDateTime,
+	"DT":             common.PvtDateTime,
+	"DTL":            common.PvtDTL,
+	"STRING":         common.PvtString,
+	"WSTRING":        common.PvtWString,
+	"TIMER":          common.PvtTimer,
+	"COUNTER":        common.PvtCounter,
+	"SINT":           common.PvtSInt,
+	"USINT":          common.PvtUSInt,
+	"UINT":           common.PvtUInt,
+	"UDINT":          common.PvtUDInt,
+	"LINT":           common.PvtLInt,
+	"ULINT":          common.PvtULInt,
+	"LWORD":          common.PvtLWord,
+	"LREAL":          common.PvtLReal,
+	"LTIME":          common.PvtLTime,
+	"LTIME_OF_DAY":   common.PvtLTimeOfDay,
+	"LTOD":           common.PvtLTimeOfDay,
+	"DATE_AND_LTIME": common.PvtLDT,
+	"LDT":            common.PvtLDT,
+	"WCHAR":          common.PvtWChar,
+}
+
+// dataTypeLength declared length of data type, e.g. String[20] -> 20, 0 if none
+func dataTypeLength(dataType string) int {
+	match := arrayAddressRegexp.FindStringSubmatch(strings.TrimSpace(dataType))
+	if match == nil {
+		return 0
+	}
+	length, _ := strconv.Atoi(match[2])
+	return length
+}
+
+// normalizeDataType upper case data type without quotes and length, e.g. String[20] -> STRING
+func normalizeDataType(dataType string) string {
+	dataType = strings.ToUpper(strings.Trim(strings.TrimSpace(dataType), "\""))
+	if i := strings.IndexByte(dataType, '['); i >= 0 {
+		dataType = dataType[:i]
+	}
+	return dataType
+}
+
+// variableTypeMnemonics variable type letters of gs7 notation
+var variableTypeMnemonics = map[common.ParamVariableType]string{
+	common.PvtBit:        "X",
+	common.PvtByte:       "B",
+	common.PvtChar:       "C",
+	common.PvtWord:       "W",
+	common.PvtInt:        "I",
+	common.PvtDWord:      "DW",
+	common.PvtDInt:       "DI",
+	common.PvtReal:       "R",
+	common.PvtTime:       "T",
+	common.PvtDate:       "D",
+	common.PvtTimeOfDay:  "TOD",
+	common.PvtDateTime:   "DT",
+	common.PvtDTL:        "DTL",
+	common.PvtS5Time:     "ST",
+	common.PvtString:     "S",
+	common.PvtWString:    "WS",
+	common.PvtSInt:       "SI",
+	common.PvtUSInt:      "USI",
+	common.PvtUInt:       "UI",
+	common.PvtUDInt:      "UDI",
+	common.PvtLInt:       "LI",
+	common.PvtULInt:      "ULI",
+	common.PvtLWord:      "LW",
+	common.PvtLReal:      "LR",
+	common.PvtLTime:      "LT",
+	common.PvtLTimeOfDay: "LTOD",
+	common.PvtLDT:        "LDT",
+	common.PvtWChar:      "WC",
+}
+
+// variableTypeNames PLC data type names of variable types
+var variableTypeNames = map[common.ParamVariableType]string{
+	common.PvtBit:        "BOOL",
+	common.PvtByte:       "BYTE",
+	common.PvtChar:       "CHAR",
+	common.PvtWord:       "WORD",
+	common.PvtInt:        "INT",
+	common.PvtDWord:      "DWORD",
+	common.PvtDInt:       "DINT",
+	common.PvtReal:       "REAL",
+	common.PvtTime:       "TIME",
+	common.PvtDate:       "DATE",
+	common.PvtTimeOfDay:  "TOD",
+	common.PvtDateTime:   "DT",
+	common.PvtDTL:        "DTL",
+	common.PvtS5Time:     "S5TIME",
+	common.PvtString:     "STRING",
+	common.PvtWString:    "WSTRING",
+	common.PvtTimer:      "TIMER",
+	common.PvtCounter:    "COUNTER",
+	common.PvtSInt:       "SINT",
+	common.PvtUSInt:      "USINT",
+	common.PvtUInt:       "UINT",
+	common.PvtUDInt:      "UDINT",
+	common.PvtLInt:       "LINT",
+	common.PvtULInt:      "ULINT",
+	common.PvtLWord:      "LWORD",
+	common.PvtLReal:      "LREAL",
+	common.PvtLTime:      "LTIME",
+	common.PvtLTimeOfDay: "LTOD",
+	common.PvtLDT:        "LDT",
+	common.PvtWChar:      "WCHAR",
+}
+
+var areaLetters = map[common.AreaType]string{
+	common.AtInputs:        "I",
+	common.AtOutputs:       "Q",
+	common.AtFlags:         "M",
+	common.AtLocalData:     "L",
+	common.AtSystemFlag:    "SM",
+	common.AtAnalogInputs:  "AI",
+	common.AtAnalogOutputs: "AQ",
+}
+
+// peripheralSizeLetter size letter of peripheral address, values longer than double word start at a byte
+func peripheralSizeLetter(variableType common.ParamVariableType) string {
+	switch variableType.Size() {
+	case 2:
+		return "W"
+	case 4:
+		return "D"
+	default:
+		return "B"
+	}
 }
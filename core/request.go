@@ -8,6 +8,8 @@ import (
 	"encoding/binary"
 	"github.com/shiyuecamus/gs7/common"
 	"github.com/shiyuecamus/gs7/util"
+	"strconv"
+	"strings"
 )
 
 type StandardRequestItem struct {
@@ -49,6 +51,15 @@ type StandardRequestItem struct {
 	// BitAddress 位地址
 	// 位于开始字节地址address中3个字节的最后3位
 	BitAddress int
+	// Array 地址带有元素个数，例如DB1.REAL0[100]，此时Count为元素个数
+	// 不参与序列化
+	Array bool
+	// MaxLength STRING/WSTRING声明的最大长度，例如DB1.S10[254]，为0时从PLC中读取
+	// 不参与序列化
+	MaxLength uint16
+	// PeripheralOutput 外设区域地址为输出，例如PQW256，外设区域不区分输入输出
+	// 不参与序列化
+	PeripheralOutput bool
 }
 
 func NewStandardRequestItem(area common.AreaType, dbNumber int, variableType common.ParamVariableType, byteAddress int, bitAddress int, count int) *StandardRequestItem {
@@ -65,6 +76,59 @@ func NewStandardRequestItem(area common.AreaType, dbNumber int, variableType com
 	}
 }
 
+// String canonical address of the item in gs7 notation, e.g. DB1.X0.0, DB1.R8, MW10, IX0.1, T5, DB1.R0[100]
+// peripheral and analog addresses use siemens notation with type suffix, e.g. PIW256, PID260:REAL, AIW0
+func (s *StandardRequestItem) String() string {
+	var sb strings.Builder
+	switch s.Area {
+	case common.AtDataBlocks:
+		sb.WriteString("DB" + strconv.Itoa(int(s.DbNumber)) + ".")
+		sb.WriteString(variableTypeMnemonics[s.VariableType] + strconv.Itoa(s.ByteAddress))
+	case common.AtInstanceDataBlocks:
+		sb.WriteString("DI" + strconv.Itoa(int(s.DbNumber)) + ".")
+		sb.WriteString(variableTypeMnemonics[s.VariableType] + strconv.Itoa(s.ByteAddress))
+	case common.AtTimers, common.AtIecTimers:
+		sb.WriteString("T" + strconv.Itoa(s.ByteAddress))
+	case common.AtCounters, common.AtIecCounters:
+		sb.WriteString("C" + strconv.Itoa(s.ByteAddress))
+	case common.AtHsCounters:
+		sb.WriteString("HC" + strconv.Itoa(s.ByteAddress))
+	case common.AtDirectPeripheralAccess:
+		direction := "PI"
+		if s.PeripheralOutput {
+			direction = "PQ"
+		}
+		sb.WriteString(direction + peripheralSizeLetter(s.VariableType) + strconv.Itoa(s.ByteAddress))
+	case common.AtAnalogInputs, common.AtAnalogOutputs:
+		sb.WriteString(areaLetters[s.Area] + peripheralSizeLetter(s.VariableType) + strconv.Itoa(s.ByteAddress))
+	default:
+		sb.WriteString(areaLetters[s.Area])
+		mnemonic := variableTypeMnemonics[s.VariableType]
+		if s.VariableType == common.PvtDate && s.Area != common.AtInputs && s.Area != common.AtOutputs && s.Area != common.AtFlags {
+			// D is DATE only for I, Q and M, e.g. LD10 is a DWord in siemens notation
+			mnemonic = "DATE"
+		}
+		sb.WriteString(mnemonic + strconv.Itoa(s.ByteAddress))
+	}
+	if s.VariableType == common.PvtBit {
+		sb.WriteString("." + strconv.Itoa(s.BitAddress))
+	}
+	if s.Array {
+		sb.WriteString("[" + strconv.Itoa(int(s.Count)) + "]")
+	} else if s.MaxLength > 0 {
+		sb.WriteString("[" + strconv.Itoa(int(s.MaxLength)) + "]")
+	}
+	switch s.Area {
+	case common.AtDirectPeripheralAccess, common.AtAnalogInputs, common.AtAnalogOutputs:
+		switch s.VariableType {
+		case common.PvtByte, common.PvtWord, common.PvtDWord:
+		default:
+			sb.WriteString(":" + variableTypeNames[s.VariableType])
+		}
+	}
+	return sb.String()
+}
+
 func (s *StandardRequestItem) Len() int {
 	return common.StandardRequestItemLen
 }
@@ -75,10 +139,21 @@ func (s *StandardRequestItem) ToBytes() []byte {
 	res = append(res, util.NumberToBytes(s.Count)...)
 	res = append(res, util.NumberToBytes(s.DbNumber)...)
 	res = append(res, byte(s.Area))
-	res = append(res, util.NumberToBytes(uint32(s.ByteAddress<<3 + s.BitAddress))[1:]...)
+	res = append(res, util.NumberToBytes(s.address())[1:]...)
 	return res
 }
 
+// address 3 bytes address of the item
+// timers and counters are addressed by their number, all other areas by bit offset
+func (s *StandardRequestItem) address() uint32 {
+	switch s.VariableType {
+	case common.PvtTimer, common.PvtCounter, common.PvtIecTimer, common.PvtIecCounter, common.PvtHsCounter:
+		return uint32(s.ByteAddress)
+	default:
+		return uint32(s.ByteAddress<<3 + s.BitAddress)
+	}
+}
+
 func StandardRequestItemFromBytes(bytes []byte) (*StandardRequestItem, error) {
 	return StandardRequestItemFromBytesWithOffset(bytes, 0)
 }
@@ -88,6 +163,10 @@ func StandardRequestItemFromBytesWithOffset(bytes []byte, offset int) (*Standard
 		return nil, common.ErrorWithCode(common.ErrModelFromBytes, "StandardRequestItem", common.StandardRequestItemLen)
 	}
 	u := binary.BigEndian.Uint32(append([]byte{0x00}, bytes[offset+9:offset+12]...))
+	switch common.ParamVariableType(bytes[offset+3]) {
+	case common.PvtTimer, common.PvtCounter, common.PvtIecTimer, common.PvtIecCounter, common.PvtHsCounter:
+		u <<= 3
+	}
 	return &StandardRequestItem{
 		SpecificationType: bytes[offset],
 		LengthOfFollowing: bytes[offset+1],
@@ -0,0 +1,212 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/util"
+)
+
+// Tag PLC tag as exported by TIA Portal "PLC tags" table
+type Tag struct {
+	// Name tag name, e.g. Pump1_Run
+	Name string
+	// Path tag table the tag belongs to
+	Path string
+	// DataType PLC data type, e.g. Bool, Int, Real
+	DataType string
+	// Address logical address, e.g. %I0.3, %MW10
+	Address string
+	// Comment tag comment
+	Comment string
+}
+
+// RequestItem resolve the request item of the tag
+// the data type of the tag decides the variable type,
+// unknown data types keep the type given by the address size (X/B/W/D)
+func (t Tag) RequestItem() (*StandardRequestItem, error) {
+	item, err := ParseAddress(t.Address)
+	if err != nil {
+		return nil, err
+	}
+	requestItem := item.(*StandardRequestItem)
+	if variableType, ok := dataTypes[normalizeDataType(t.DataType)]; ok {
+		if err = setDataType(requestItem, variableType); err != nil {
+			return nil, err
+		}
+		if variableType == common.PvtString || variableType == common.PvtWString {
+			if err = setMaxLength(requestItem, dataTypeLength(t.DataType)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return requestItem, nil
+}
+
+// TagTable PLC tags indexed by name
+type TagTable struct {
+	tags  map[string]Tag
+	names []string
+}
+
+func NewTagTable(tags ...Tag) *TagTable {
+	t := &TagTable{
+		tags:  make(map[string]Tag, len(tags)),
+		names: make([]string, 0, len(tags)),
+	}
+	for _, tag := range tags {
+		t.Add(tag)
+	}
+	return t
+}
+
+// Add add or replace a tag
+func (t *TagTable) Add(tag Tag) {
+	if _, ok := t.tags[tag.Name]; !ok {
+		t.names = append(t.names, tag.Name)
+	}
+	t.tags[tag.Name] = tag
+}
+
+// Get get tag by name
+func (t *TagTable) Get(name string) (Tag, bool) {
+	tag, ok := t.tags[name]
+	return tag, ok
+}
+
+// Names tag names in import order
+func (t *TagTable) Names() []string {
+	return append([]string(nil), t.names...)
+}
+
+func (t *TagTable) Len() int {
+	return len(t.names)
+}
+
+// TagTableFromFile import tag table from TIA export file
+// the format is chosen by file extension: .xlsx or .csv
+func TagTableFromFile(name string) (*TagTable, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".xlsx":
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return TagTableFromXLSX(f, info.Size())
+	case ".csv":
+		return TagTableFromCSV(f)
+	default:
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, "unsupported file extension "+ext)
+	}
+}
+
+// TagTableFromXLSX import tag table from TIA "PLC Tags" xlsx export
+func TagTableFromXLSX(r io.ReaderAt, size int64) (*TagTable, error) {
+	rows, err := util.ReadXlsxSheet(r, size, "PLC Tags")
+	if err != nil {
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, err.Error())
+	}
+	return tagTableFromRows(rows)
+}
+
+// TagTableFromCSV import tag table from csv
+// the delimiter (comma, semicolon or tab) is detected from the header line
+func TagTableFromCSV(r io.Reader) (*TagTable, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.Comma = ','
+	for _, comma := range []rune{';', '\t'} {
+		if bytes.Count(header, []byte(string(comma))) > bytes.Count(header, []byte(string(reader.Comma))) {
+			reader.Comma = comma
+		}
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, err.Error())
+	}
+	return tagTableFromRows(rows)
+}
+
+func tagTableFromRows(rows [][]string) (*TagTable, error) {
+	if len(rows) == 0 {
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, "header not found")
+	}
+	columns := map[string]int{}
+	for i, title := range rows[0] {
+		title = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(title, "\uFEFF")))
+		if column, ok := tagColumns[title]; ok {
+			if _, exists := columns[column]; !exists {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, "name column not found")
+	}
+	if _, ok := columns["address"]; !ok {
+		return nil, common.ErrorWithCode(common.ErrTagTableInvalid, "logical address column not found")
+	}
+	cell := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	t := NewTagTable()
+	for _, row := range rows[1:] {
+		tag := Tag{
+			Name:     cell(row, "name"),
+			Path:     cell(row, "path"),
+			DataType: cell(row, "dataType"),
+			Address:  cell(row, "address"),
+			Comment:  cell(row, "comment"),
+		}
+		if tag.Name == "" || tag.Address == "" {
+			continue
+		}
+		t.Add(tag)
+	}
+	return t, nil
+}
+
+// tagColumns column titles of english and german TIA exports
+var tagColumns = map[string]string{
+	"name":             "name",
+	"path":             "path",
+	"pfad":             "path",
+	"data type":        "dataType",
+	"datatype":         "dataType",
+	"datentyp":         "dataType",
+	"logical address":  "address",
+	"address":          "address",
+	"logische adresse": "address",
+	"adresse":          "address",
+	"comment":          "comment",
+	"kommentar":        "comment",
+}
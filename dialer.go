@@ -0,0 +1,95 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"bufio"
+	"context"
+	"github.com/shiyuecamus/gs7/common"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Dialer dial the connection to plc, e.g. through a jump host or proxy, see NewSOCKS5Dialer and NewHTTPConnectDialer.
+// The DialContext of an ssh client is a Dialer of an ssh tunnel
+type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+
+// NewSOCKS5Dialer dialer through the SOCKS5 proxy, username and password are optional
+func NewSOCKS5Dialer(proxyAddress string, username string, password string) (Dialer, error) {
+	var auth *proxy.Auth
+	if username != "" {
+		auth = &proxy.Auth{User: username, Password: password}
+	}
+	d, err := proxy.SOCKS5("tcp", proxyAddress, auth, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	return d.(proxy.ContextDialer).DialContext, nil
+}
+
+// NewHTTPConnectDialer dialer through the http proxy by the CONNECT method,
+// header is sent with the request, e.g. Proxy-Authorization
+func NewHTTPConnectDialer(proxyAddress string, header http.Header) Dialer {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, proxyAddress)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+		request := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: header,
+		}
+		if err = request.Write(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		reader := bufio.NewReader(conn)
+		response, err := http.ReadResponse(reader, request)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			_ = conn.Close()
+			return nil, common.ErrorWithCode(common.ErrTcpProxyConnect, proxyAddress, address, response.Status)
+		}
+		_ = conn.SetDeadline(time.Time{})
+		// bytes read behind the response are the first of the plc, they are read before the connection
+		if reader.Buffered() > 0 {
+			return &bufferedConn{Conn: conn, reader: reader}, nil
+		}
+		return conn, nil
+	}
+}
+
+// bufferedConn connection read through the reader of bytes received before it is returned
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// dialOnce dial the endpoint by the dialer, or the transport without dialer
+func (c *client) dialOnce(endpoint string) (net.Conn, error) {
+	if c.dialer == nil {
+		return c.transport.Dial(endpoint, c.timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.dialer(ctx, "tcp", endpoint)
+}
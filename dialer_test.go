@@ -0,0 +1,163 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"github.com/shiyuecamus/gs7/common"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listen serve the accepted connections of a local listener until the test ends
+func listen(t *testing.T, serve func(conn net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// relay copy between the connections until one of them is closed
+func relay(conn net.Conn, reader io.Reader, target net.Conn) {
+	go func() {
+		_, _ = io.Copy(target, reader)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+	_ = conn.Close()
+}
+
+// socks5Proxy SOCKS5 proxy without authentication, connecting to ip addresses
+func socks5Proxy(conn net.Conn) {
+	buf := make([]byte, 256)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	_, _ = conn.Write([]byte{0x05, 0x00})
+	// version, connect, reserved, ipv4, address, port
+	if _, err := io.ReadFull(conn, buf[:10]); err != nil || buf[3] != 0x01 {
+		_ = conn.Close()
+		return
+	}
+	address := net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(buf[8:]))))
+	target, err := net.Dial("tcp", address)
+	if err != nil {
+		_, _ = conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		_ = conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	relay(conn, conn, target)
+}
+
+// httpProxy http proxy of the CONNECT method, the greeting of the target is sent with the response
+func httpProxy(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil || request.Method != http.MethodConnect || request.Header.Get("Proxy-Authorization") != "Basic dGVzdA==" {
+		_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		_ = conn.Close()
+		return
+	}
+	target, err := net.Dial("tcp", request.Host)
+	if err != nil {
+		_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		_ = conn.Close()
+		return
+	}
+	response := []byte("HTTP/1.1 200 Connection Established\r\n\r\n")
+	_ = target.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	greeting := make([]byte, 64)
+	n, _ := target.Read(greeting)
+	_ = target.SetReadDeadline(time.Time{})
+	_, _ = conn.Write(append(response, greeting[:n]...))
+	relay(conn, reader, target)
+}
+
+func testDialer(t *testing.T, dialer Dialer) {
+	plc := newTestPlc()
+	host, port, _ := net.SplitHostPort(listen(t, plc.serve))
+	p, _ := strconv.Atoi(port)
+	c := NewClientBuilder().Host(host).Port(p).PlcType(common.S1500).Dialer(dialer).Timeout(2 * time.Second).Build()
+	if _, err := c.Connect().Wait(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.WriteRaw("DB1.B0[2]", []byte{0x12, 0x34}).Wait(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := c.ReadRaw("DB1.B0[2]").Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Value[0] != 0x12 || raw.Value[1] != 0x34 {
+		t.Fatalf("read % x, want 12 34", raw.Value)
+	}
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	dialer, err := NewSOCKS5Dialer(listen(t, socks5Proxy), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDialer(t, dialer)
+}
+
+func TestHTTPConnectDialer(t *testing.T) {
+	header := http.Header{}
+	header.Set("Proxy-Authorization", "Basic dGVzdA==")
+	dialer := NewHTTPConnectDialer(listen(t, httpProxy), header)
+	testDialer(t, dialer)
+
+	_, err := NewHTTPConnectDialer(listen(t, httpProxy), nil)(context.Background(), "tcp", "127.0.0.1:102")
+	if err == nil {
+		t.Fatal("connected without authorization")
+	}
+}
+
+func TestHTTPConnectDialerBuffered(t *testing.T) {
+	target := listen(t, func(conn net.Conn) {
+		_, _ = conn.Write([]byte("hello"))
+		_, _ = io.Copy(conn, conn)
+	})
+	header := http.Header{}
+	header.Set("Proxy-Authorization", "Basic dGVzdA==")
+	conn, err := NewHTTPConnectDialer(listen(t, httpProxy), header)(context.Background(), "tcp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = conn.Write([]byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	received := make([]byte, 11)
+	if _, err = io.ReadFull(conn, received); err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != "hello world" {
+		t.Fatalf("received %q, want %q", received, "hello world")
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"github.com/shiyuecamus/gs7/util"
+	"github.com/spf13/cast"
+)
+
+// encoder encode the value by the variable type of the address
+// values are go natives (bool, int16, float32, string, time.Time, time.Duration ...) or gs7 types (Real, Int ...),
+// arrays are slices of them, e.g. []float32 for DB1.REAL0[10], CHAR arrays also take string
+type encoder struct {
+	plcType   common.PlcType
+	pduLength int
+	charset   common.Charset
+}
+
+func (e encoder) Encode(item *core.StandardRequestItem, value any) ([]byte, error) {
+	if item.Array {
+		return e.encodeArray(item, value)
+	}
+	return e.encode(item.VariableType, value)
+}
+
+func (e encoder) encodeArray(item *core.StandardRequestItem, value any) ([]byte, error) {
+	if s, ok := value.(string); ok && item.VariableType == common.PvtChar {
+		// text of CHAR array, padded with zeros
+		bs, err := e.charset.Encode(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) > int(item.Count) {
+			return nil, fmt.Errorf("text longer than [%d] bytes", item.Count)
+		}
+		return append(bs, make([]byte, int(item.Count)-len(bs))...), nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("array of [%d] elements expected", item.Count)
+	}
+	if v.Len() != int(item.Count) {
+		return nil, fmt.Errorf("array of [%d] elements expected, got [%d]", item.Count, v.Len())
+	}
+	if item.VariableType == common.PvtBit {
+		bools := make([]bool, v.Len())
+		for i := range bools {
+			b, err := toBool(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			bools[i] = b
+		}
+		return util.EncodeBools(bools), nil
+	}
+	res := make([]byte, 0, v.Len()*int(item.VariableType.Size()))
+	for i := 0; i < v.Len(); i++ {
+		bs, err := e.encode(item.VariableType, v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, bs...)
+	}
+	return res, nil
+}
+
+func (e encoder) encode(variableType common.ParamVariableType, value any) (bs []byte, err error) {
+	switch v := value.(type) {
+	case String:
+		return e.encodeString(variableType, string(v))
+	case WString:
+		return e.encodeString(variableType, string(v))
+	case interface{ ToBytes() []byte }:
+		// gs7 types are taken as they are, the size must match the address
+		if bs = v.ToBytes(); len(bs) != int(variableType.Size()) {
+			return nil, errors.New("size mismatch")
+		}
+		return
+	}
+
+	var i int64
+	switch variableType {
+	case common.PvtBit:
+		var b bool
+		if b, err = toBool(value); err == nil {
+			bs = Bit(b).ToBytes()
+		}
+	case common.PvtByte:
+		if i, err = toInt(value, 0, math.MaxUint8); err == nil {
+			bs = Byte(i).ToBytes()
+		}
+	case common.PvtChar:
+		if s, ok := value.(string); ok {
+			if bs, err = e.charset.Encode(s); err == nil && len(bs) != 1 {
+				err = errors.New("single byte character expected")
+			}
+			return
+		}
+		if i, err = toInt(value, math.MinInt8, math.MaxUint8); err == nil {
+			bs = []byte{byte(i)}
+		}
+	case common.PvtWord:
+		if i, err = toInt(value, 0, math.MaxUint16); err == nil {
+			bs = Word(i).ToBytes()
+		}
+	case common.PvtInt:
+		if i, err = toInt(value, math.MinInt16, math.MaxInt16); err == nil {
+			bs = Int(i).ToBytes()
+		}
+	case common.PvtDWord:
+		if i, err = toInt(value, 0, math.MaxUint32); err == nil {
+			bs = DWord(i).ToBytes()
+		}
+	case common.PvtDInt:
+		if i, err = toInt(value, math.MinInt32, math.MaxInt32); err == nil {
+			bs = DInt(i).ToBytes()
+		}
+	case common.PvtReal:
+		var f float32
+		if f, err = cast.ToFloat32E(value); err == nil {
+			bs = Real(f).ToBytes()
+		}
+	case common.PvtSInt:
+		if i, err = toInt(value, math.MinInt8, math.MaxInt8); err == nil {
+			bs = SInt(i).ToBytes()
+		}
+	case common.PvtUSInt:
+		if i, err = toInt(value, 0, math.MaxUint8); err == nil {
+			bs = USInt(i).ToBytes()
+		}
+	case common.PvtUInt:
+		if i, err = toInt(value, 0, math.MaxUint16); err == nil {
+			bs = UInt(i).ToBytes()
+		}
+	case common.PvtUDInt:
+		if i, err = toInt(value, 0, math.MaxUint32); err == nil {
+			bs = UDInt(i).ToBytes()
+		}
+	case common.PvtLInt:
+		if i, err = toInt(value, math.MinInt64, math.MaxInt64); err == nil {
+			bs = LInt(i).ToBytes()
+		}
+	case common.PvtULInt, common.PvtLWord:
+		var u uint64
+		if u, err = cast.ToUint64E(value); err == nil {
+			bs = ULInt(u).ToBytes()
+		}
+	case common.PvtLReal:
+		var f float64
+		if f, err = cast.ToFloat64E(value); err == nil {
+			bs = LReal(f).ToBytes()
+		}
+	case common.PvtTime, common.PvtS5Time, common.PvtTimer, common.PvtLTime:
+		bs, err = e.encodeDuration(variableType, value)
+	case common.PvtDate, common.PvtTimeOfDay, common.PvtDateTime, common.PvtDTL, common.PvtLTimeOfDay, common.PvtLDT:
+		bs, err = e.encodeTime(variableType, value)
+	case common.PvtCounter:
+		if i, err = toInt(value, 0, 999); err == nil {
+			bs = Counter(i).ToBytes()
+		}
+	case common.PvtIecCounter, common.PvtIecTimer:
+		if i, err = toInt(value, math.MinInt16, math.MaxInt16); err == nil {
+			bs = Int(i).ToBytes()
+		}
+	case common.PvtHsCounter:
+		if i, err = toInt(value, math.MinInt32, math.MaxInt32); err == nil {
+			bs = DInt(i).ToBytes()
+		}
+	case common.PvtWChar:
+		if s, ok := value.(string); ok {
+			if utf8.RuneCountInString(s) != 1 {
+				return nil, errors.New("single character expected")
+			}
+			r, _ := utf8.DecodeRuneInString(s)
+			return WChar(r).ToBytes(), nil
+		}
+		if i, err = toInt(value, 0, math.MaxUint16); err == nil {
+			bs = WChar(i).ToBytes()
+		}
+	case common.PvtString, common.PvtWString:
+		var s string
+		if s, err = cast.ToStringE(value); err == nil {
+			bs, err = e.encodeString(variableType, s)
+		}
+	default:
+		err = common.ErrorWithCode(common.ErrVariableTypeUnrecognized, variableType)
+	}
+	return
+}
+
+func (e encoder) encodeDuration(variableType common.ParamVariableType, value any) ([]byte, error) {
+	d, err := cast.ToDurationE(value)
+	if err != nil {
+		return nil, err
+	}
+	switch variableType {
+	case common.PvtTime:
+		return Time(d).ToBytes(), nil
+	case common.PvtS5Time:
+		return S5Time(d).ToBytes(), nil
+	case common.PvtTimer:
+		return Timer(d).ToBytes(), nil
+	default:
+		return LTime(d).ToBytes(), nil
+	}
+}
+
+func (e encoder) encodeTime(variableType common.ParamVariableType, value any) ([]byte, error) {
+	t, err := cast.ToTimeE(value)
+	if err != nil {
+		return nil, err
+	}
+	switch variableType {
+	case common.PvtDate:
+		return Date(t).ToBytes(), nil
+	case common.PvtTimeOfDay:
+		return TimeOfDay(t).ToBytes(), nil
+	case common.PvtDateTime:
+		return DateTime(t).ToBytes(), nil
+	case common.PvtDTL:
+		return DateTimeLong(t).ToBytes(), nil
+	case common.PvtLTimeOfDay:
+		return LTimeOfDay(t).ToBytes(), nil
+	default:
+		return LDateTime(t).ToBytes(), nil
+	}
+}
+
+// encodeString S7-200 smart strings have a one byte header of length, wide strings one byte each of max length and length
+func (e encoder) encodeString(variableType common.ParamVariableType, s string) ([]byte, error) {
+	switch variableType {
+	case common.PvtString:
+		bs, err := String(s).Encode(e.pduLength, e.charset)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs)-2 > 254 {
+			return nil, errors.New("string longer than 254 bytes")
+		}
+		if e.plcType == common.S200Smart {
+			bs = bs[1:]
+		}
+		return bs, nil
+	case common.PvtWString:
+		bs := WString(s).ToBytes(e.pduLength)
+		if (len(bs)-4)/2 > 16382 {
+			return nil, errors.New("wide string longer than 16382 characters")
+		}
+		if e.plcType == common.S200Smart {
+			bs = append([]byte{bs[1], bs[3]}, bs[4:]...)
+		}
+		return bs, nil
+	default:
+		return nil, errors.New("string is not expected")
+	}
+}
+
+func toBool(value any) (bool, error) {
+	if b, ok := value.(Bit); ok {
+		return bool(b), nil
+	}
+	return cast.ToBoolE(value)
+}
+
+func toInt(value any, min int64, max int64) (int64, error) {
+	if f, err := cast.ToFloat64E(value); err == nil && f != math.Trunc(f) {
+		return 0, errors.New("integer expected")
+	}
+	i, err := cast.ToInt64E(value)
+	if err != nil {
+		return 0, err
+	}
+	if i < min || i > max {
+		return 0, fmt.Errorf("out of range [%d, %d]", min, max)
+	}
+	return i, nil
+}
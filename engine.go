@@ -0,0 +1,90 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"github.com/shiyuecamus/gs7/logging"
+	"sync"
+)
+
+const (
+	// DefaultEngineWorkers max goroutines of the workers of an engine
+	DefaultEngineWorkers = 64
+	// DefaultClientWorkers max goroutines of the workers of a client without engine
+	DefaultClientWorkers = 4
+)
+
+// Engine runtime shared by many clients, registered by ClientBuilder.Engine:
+// the event loops of one transport and a bounded pool of workers writing the requests and completing the responses,
+// so the goroutines do not grow with the clients and the requests in flight
+type Engine struct {
+	transport Transport
+	pool      *workerPool
+}
+
+// NewEngine engine of the event loops of a gnet transport, one per cpu core, and workers goroutines at most,
+// workers <= 0 DefaultEngineWorkers
+func NewEngine(logger logging.Logger, workers int) (*Engine, error) {
+	transport, err := NewGnetTransport(logger)
+	if err != nil {
+		return nil, err
+	}
+	return NewEngineWithTransport(transport, workers), nil
+}
+
+// NewEngineWithTransport engine of the transport and workers goroutines at most, workers <= 0 DefaultEngineWorkers
+func NewEngineWithTransport(transport Transport, workers int) *Engine {
+	if workers <= 0 {
+		workers = DefaultEngineWorkers
+	}
+	return &Engine{transport: transport, pool: newWorkerPool(workers)}
+}
+
+// Close close the transport and the connections of the clients, the clients are disconnected
+func (e *Engine) Close() error {
+	return e.transport.Close()
+}
+
+// workerPool run the tasks on size goroutines at most, tasks are queued without limit so submit never blocks,
+// e.g. on an event loop. Idle workers exit, a pool without tasks has no goroutines.
+// Tasks must not wait for other tasks of the pool
+type workerPool struct {
+	m       sync.Mutex
+	size    int
+	workers int
+	busy    int
+	tasks   []func()
+}
+
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{size: max(size, 1)}
+}
+
+// submit queue the task, a worker is started if all are busy
+func (p *workerPool) submit(task func()) {
+	p.m.Lock()
+	p.tasks = append(p.tasks, task)
+	if p.workers < p.size && len(p.tasks) > p.workers-p.busy {
+		p.workers++
+		go p.work()
+	}
+	p.m.Unlock()
+}
+
+func (p *workerPool) work() {
+	p.m.Lock()
+	for len(p.tasks) > 0 {
+		task := p.tasks[0]
+		p.tasks[0] = nil
+		p.tasks = p.tasks[1:]
+		p.busy++
+		p.m.Unlock()
+		task()
+		p.m.Lock()
+		p.busy--
+	}
+	p.workers--
+	p.m.Unlock()
+}
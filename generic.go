@@ -0,0 +1,117 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"reflect"
+
+	"github.com/shiyuecamus/gs7/common"
+)
+
+// Read read address and decode the value to T
+// T is the go type of the address (bool, int16, float32, time.Time, time.Duration, string ...),
+// a gs7 type (Real, Int ...) or a slice of them for arrays, e.g. []float32 for DB1.REAL0[10]
+//
+//	v, err := gs7.Read[float32](c, "DB1.R0")
+func Read[T any](c Client, address string, opts ...CallOption) (T, error) {
+	return ReadAsync[T](c, address, opts...).Wait()
+}
+
+// ReadMany read addresses in batch and decode the values to T
+// CHAR arrays can be read as string, decoded in the charset of client
+func ReadMany[T any](c Client, addresses []string, opts ...CallOption) ([]T, error) {
+	return ReadManyAsync[T](c, addresses, opts...).Wait()
+}
+
+// TypedReadToken token of the values decoded to T by ReadAsync and ReadManyAsync
+type TypedReadToken[T any] struct {
+	baseToken[T]
+}
+
+// ReadAsync read address and decode the value to T like Read, without waiting for the response
+func ReadAsync[T any](c Client, address string, opts ...CallOption) *TypedReadToken[T] {
+	token := &TypedReadToken[T]{baseToken[T]{complete: make(chan struct{})}}
+	ReadManyAsync[T](c, []string{address}, opts...).then(func(v []T, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		token.v = v[0]
+		token.flowComplete()
+	})
+	return token
+}
+
+// ReadManyAsync read addresses in batch and decode the values to T like ReadMany, without waiting for the response
+func ReadManyAsync[T any](c Client, addresses []string, opts ...CallOption) *TypedReadToken[[]T] {
+	token := &TypedReadToken[[]T]{baseToken[[]T]{complete: make(chan struct{})}}
+	c.ReadBatchRaw(addresses, opts...).then(func(infos []RawInfo, err error) {
+		if err != nil {
+			token.setError(err)
+			return
+		}
+		if token.v, err = decodeValues[T](addresses, infos); err != nil {
+			token.setError(err)
+			return
+		}
+		token.flowComplete()
+	})
+	return token
+}
+
+// decodeValues decode the raw values of the addresses to T
+func decodeValues[T any](addresses []string, infos []RawInfo) ([]T, error) {
+	res := make([]T, len(infos))
+	text := reflect.TypeOf(res).Elem().Kind() == reflect.String
+	for i, info := range infos {
+		var v any
+		var err error
+		if text && info.Type == common.PvtChar && info.Count > 0 {
+			v, err = info.Text()
+		} else {
+			v, err = info.Parse()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res[i], err = convertValue[T](addresses[i], v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// convertValue convert parsed value to T, only types of the same kind are compatible,
+// e.g. Real to float32, Date to time.Time, []Int to []int16
+func convertValue[T any](address string, v any) (res T, err error) {
+	if t, ok := v.(T); ok {
+		return t, nil
+	}
+	target := reflect.TypeOf(&res).Elem()
+	rv, ok := convertReflect(reflect.ValueOf(v), target)
+	if !ok {
+		err = common.ErrorWithCode(common.ErrCliValueTypeMismatch, address, v, target.String())
+		return
+	}
+	return rv.Interface().(T), nil
+}
+
+func convertReflect(v reflect.Value, target reflect.Type) (reflect.Value, bool) {
+	if v.Kind() == reflect.Slice && target.Kind() == reflect.Slice {
+		res := reflect.MakeSlice(target, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			e, ok := convertReflect(v.Index(i), target.Elem())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			res.Index(i).Set(e)
+		}
+		return res, true
+	}
+	if v.Kind() != target.Kind() || !v.Type().ConvertibleTo(target) {
+		return reflect.Value{}, false
+	}
+	return v.Convert(target), true
+}
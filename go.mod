@@ -3,14 +3,26 @@ module github.com/shiyuecamus/gs7
 go 1.21
 
 require (
-	github.com/panjf2000/gnet/v2 v2.3.5 // indirect
-	github.com/spf13/cast v1.6.0 // indirect
+	github.com/panjf2000/gnet/v2 v2.3.5
+	github.com/prometheus/client_golang v1.19.0
+	github.com/spf13/cast v1.6.0
+	go.uber.org/zap v1.27.0
+	golang.org/x/net v0.20.0
+	golang.org/x/text v0.14.0
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
-	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
+	google.golang.org/protobuf v1.32.0 // indirect
 )
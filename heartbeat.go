@@ -0,0 +1,99 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"net"
+	"time"
+)
+
+// DefaultHeartbeatMisses default missed heartbeats before the connection is dead
+const DefaultHeartbeatMisses = 3
+
+// heartbeat probe the connection with a one byte read of MB0 when idle for heartbeatInterval,
+// the connection is closed after heartbeatMisses missed probes in a row, and reconnected if auto reconnect is set.
+// The probes are started by timers on the workers, no goroutine waits for the idle time or the response.
+// It ends when the connection is closed or replaced
+func (c *client) heartbeat(conn net.Conn) {
+	probe := []common.RequestItem{core.NewStandardRequestItem(common.AtFlags, 0, common.PvtByte, 0, 0, 1)}
+	misses := 0
+	var tick func()
+	tick = func() {
+		if c.GetConn() != conn {
+			return
+		}
+		if idle := time.Since(time.Unix(0, c.lastActivity.Load())); idle < c.heartbeatInterval {
+			time.AfterFunc(c.heartbeatInterval-idle, func() { c.pool.submit(tick) })
+			return
+		}
+		c.readSplit(probe, PrControl).then(func(_ []*core.DataItem, err error) {
+			if c.GetConn() != conn {
+				return
+			}
+			if !heartbeatMissed(err) {
+				misses = 0
+				tick()
+				return
+			}
+			misses++
+			c.logger.Debugf("S7 client for [%s:%d] missed heartbeat [%d/%d]: %v", c.host, c.port, misses, c.heartbeatMisses, err)
+			if misses >= c.heartbeatMisses {
+				c.logger.Warnf("S7 client for [%s:%d] missed [%d] heartbeats, connection is dead", c.host, c.port, misses)
+				c.closeDead(conn, common.ErrorWithCode(common.ErrCliHeartbeatMissed, fmt.Sprintf("%s:%d", c.host, c.port), misses))
+				return
+			}
+			// the next probe right after the interval, not after the response timeout
+			c.lastActivity.Store(time.Now().UnixNano())
+			tick()
+		})
+	}
+	tick()
+}
+
+// heartbeatMissed the probe got no answer: it timed out or failed on the connection.
+// An error answered by plc, e.g. access denied on CPUs protecting the M area, proves the connection alive
+func heartbeatMissed(err error) bool {
+	var (
+		s7Err         *common.Error
+		returnCodeErr common.ReturnCodeError
+	)
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, common.ErrTcpRequestTimeout):
+		return true
+	case errors.As(err, &returnCodeErr), errors.As(err, &s7Err):
+		return false
+	default:
+		// errors of the connection, e.g. the write failed
+		return true
+	}
+}
+
+// closeDead close the dead connection, the connection lost is handled by tcpOnClose with the cause
+func (c *client) closeDead(conn net.Conn, cause error) {
+	c.m.Lock()
+	c.deadCause = cause
+	c.m.Unlock()
+	_ = conn.Close()
+}
+
+// setKeepAlive tcp keep-alive of the dialed connection, negative disables, zero keeps the default of go
+func (c *client) setKeepAlive(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok || c.keepAlive == 0 {
+		return
+	}
+	if c.keepAlive < 0 {
+		_ = tcpConn.SetKeepAlive(false)
+		return
+	}
+	_ = tcpConn.SetKeepAlive(true)
+	_ = tcpConn.SetKeepAlivePeriod(c.keepAlive)
+}
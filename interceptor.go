@@ -0,0 +1,37 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"github.com/shiyuecamus/gs7/core"
+)
+
+// SendFunc send the request pdu to plc and wait for the response pdu
+// the response may be set together with the error, e.g. an error class in the ack header
+type SendFunc func(request *core.PDU, priority Priority) (*core.PDU, error)
+
+// Interceptor wrap the send of every request pdu, including the internal requests of
+// reads split by pdu length, UploadFile chunks, SZL reads of GetCatalog and the connect requests.
+// E.g. tracing spans, auditing, request mutation, caching or custom retries.
+// Each intercepted request runs on a goroutine of its own, not on the workers of the client or engine,
+// so interceptors may block until the response
+//
+//	func(next gs7.SendFunc) gs7.SendFunc {
+//		return func(request *core.PDU, priority gs7.Priority) (*core.PDU, error) {
+//			start := time.Now()
+//			ack, err := next(request, priority)
+//			log.Printf("pdu [%d] done in %v", request.GetHeader().GetPduReference(), time.Since(start))
+//			return ack, err
+//		}
+//	}
+type Interceptor func(next SendFunc) SendFunc
+
+// chainInterceptors wrap send with the interceptors, the first interceptor is the outermost
+func chainInterceptors(interceptors []Interceptor, send SendFunc) SendFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		send = interceptors[i](send)
+	}
+	return send
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"encoding/binary"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveMinRate lowest requests per second of adaptive mode
+	adaptiveMinRate = 1.0
+	// adaptiveHold min time between two decreases of the rate
+	adaptiveHold = time.Second
+	// adaptiveWarmup latency samples before rising latency is detected
+	adaptiveWarmup = 20
+)
+
+// limiter limits the requests and bytes per second of a client, requests are spaced evenly.
+// In adaptive mode the request rate is halved when the plc reports missing resources or the latency rises,
+// and increased by about one request per second each second without (AIMD).
+type limiter struct {
+	m sync.Mutex
+	// requestRate configured max requests per second, 0 unlimited
+	requestRate float64
+	// byteRate configured max bytes per second, 0 unlimited
+	byteRate float64
+	adaptive bool
+
+	// rate current requests per second of adaptive mode, 0 unlimited
+	rate float64
+	// ceiling rate before the first decrease, unlimited again when reached
+	ceiling        float64
+	lastDecrease   time.Time
+	nextRequest    time.Time
+	nextBytes      time.Time
+	windowStart    time.Time
+	windowRequests int
+	lastWindowRate float64
+	// baseline slow and recent fast moving average of the latency
+	baseline time.Duration
+	recent   time.Duration
+	samples  int
+}
+
+func newLimiter(requestRate float64, byteRate int, adaptive bool) *limiter {
+	if requestRate <= 0 && byteRate <= 0 && !adaptive {
+		return nil
+	}
+	return &limiter{
+		requestRate: max(requestRate, 0),
+		byteRate:    float64(max(byteRate, 0)),
+		adaptive:    adaptive,
+		rate:        max(requestRate, 0),
+	}
+}
+
+// reserve the start of a request of size bytes, the delay until it may be sent
+func (l *limiter) reserve(size int) time.Duration {
+	l.m.Lock()
+	now := time.Now()
+	var delay time.Duration
+	if l.rate > 0 {
+		start := later(l.nextRequest, now)
+		l.nextRequest = start.Add(time.Duration(float64(time.Second) / l.rate))
+		delay = start.Sub(now)
+	}
+	if l.byteRate > 0 {
+		start := later(l.nextBytes, now)
+		l.nextBytes = start.Add(l.bytesDuration(size))
+		delay = max(delay, start.Sub(now))
+	}
+	l.m.Unlock()
+	return delay
+}
+
+// done account the response of a request, the response bytes delay the next requests
+func (l *limiter) done(size int, latency time.Duration, exhausted bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	now := time.Now()
+	if l.byteRate > 0 {
+		l.nextBytes = later(l.nextBytes, now).Add(l.bytesDuration(size))
+	}
+	if !l.adaptive {
+		return
+	}
+
+	// requests per second of the last second, start of the decrease when unlimited
+	if l.windowStart.IsZero() || now.Sub(l.windowStart) >= time.Second {
+		if !l.windowStart.IsZero() {
+			l.lastWindowRate = float64(l.windowRequests) / now.Sub(l.windowStart).Seconds()
+		}
+		l.windowStart, l.windowRequests = now, 0
+	}
+	l.windowRequests++
+
+	rising := l.observe(latency)
+	if (exhausted || rising) && now.Sub(l.lastDecrease) >= adaptiveHold {
+		current := l.rate
+		if current <= 0 {
+			current = l.lastWindowRate
+			if elapsed := now.Sub(l.windowStart).Seconds(); elapsed > 0 {
+				current = max(current, float64(l.windowRequests)/elapsed)
+			}
+			current = max(current, adaptiveMinRate*2)
+			l.ceiling = current
+		}
+		l.rate = max(current/2, adaptiveMinRate)
+		l.lastDecrease = now
+		return
+	}
+	if l.rate > 0 && !exhausted && !rising {
+		// about one request per second more each second
+		l.rate += 1 / l.rate
+		switch {
+		case l.requestRate > 0 && l.rate >= l.requestRate:
+			l.rate = l.requestRate
+		case l.requestRate <= 0 && l.ceiling > 0 && l.rate >= l.ceiling:
+			l.rate = 0
+		}
+	}
+}
+
+// observe add the latency sample, true if the recent latency is more than twice the baseline
+func (l *limiter) observe(latency time.Duration) bool {
+	if l.samples == 0 {
+		l.baseline, l.recent = latency, latency
+	}
+	l.samples++
+	l.recent += (latency - l.recent) * 3 / 10
+	rising := l.samples > adaptiveWarmup && l.recent > 2*l.baseline
+	if !rising {
+		// the baseline follows slowly and does not learn from congestion
+		l.baseline += (latency - l.baseline) / 100
+	}
+	return rising
+}
+
+func (l *limiter) bytesDuration(size int) time.Duration {
+	return time.Duration(float64(size) * float64(time.Second) / l.byteRate)
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// resourceExhausted the ack reports missing resources of plc
+func resourceExhausted(ack *core.PDU) bool {
+	if ack == nil {
+		return false
+	}
+	if header, ok := ack.GetHeader().(*core.AckHeader); ok && header.ErrorClass != 0x00 {
+		return common.IsResourceError(header.ErrorClass, binary.BigEndian.Uint16(header.ErrorCode))
+	}
+	if parameter, ok := ack.GetParameter().(*core.UserdataAckParameter); ok && parameter.ErrorClass != 0x00 {
+		return common.IsResourceError(parameter.ErrorClass, binary.BigEndian.Uint16(parameter.ErrorCode))
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"time"
+)
+
+// operations of the requests sent to plc
+const (
+	OpConnect    = "connect"
+	OpDisconnect = "disconnect"
+	OpSetup      = "setup"
+	OpRead       = "read"
+	OpWrite      = "write"
+	OpUpload     = "upload"
+	OpDownload   = "download"
+	OpControl    = "control"
+	OpUserdata   = "userdata"
+	OpOther      = "other"
+)
+
+// Metrics records the operations of a client, must be safe for concurrent use
+type Metrics interface {
+	// Operation a request of the operation is done, err is nil on success
+	Operation(operation string, latency time.Duration, err error)
+	// Splits pdus a read or write call was divided into
+	Splits(operation string, pdus int)
+	// BytesSent bytes of a pdu sent to plc
+	BytesSent(n int)
+	// BytesReceived bytes of a pdu received from plc
+	BytesReceived(n int)
+	// Timeout a request got no response in time
+	Timeout()
+	// Reject a request was rejected by plc, e.g. iso connect, or by the client, e.g. connection inactive
+	Reject()
+	// Reconnect a reconnect attempt after connection lose
+	Reconnect()
+}
+
+// Nop records nothing, default of the client
+type Nop struct{}
+
+func (Nop) Operation(string, time.Duration, error) {}
+
+func (Nop) Splits(string, int) {}
+
+func (Nop) BytesSent(int) {}
+
+func (Nop) BytesReceived(int) {}
+
+func (Nop) Timeout() {}
+
+func (Nop) Reject() {}
+
+func (Nop) Reconnect() {}
@@ -0,0 +1,129 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// Prometheus metrics of a client as prometheus collector, register it and pass it to the client builder
+//
+//	m := metrics.NewPrometheus("s7", prometheus.Labels{"plc": "line1"})
+//	prometheus.MustRegister(m)
+//	c := gs7.NewClientBuilder().Metrics(m).Build()
+type Prometheus struct {
+	operations *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	splits     *prometheus.HistogramVec
+	bytes      *prometheus.CounterVec
+	timeouts   prometheus.Counter
+	rejects    prometheus.Counter
+	reconnects prometheus.Counter
+}
+
+func NewPrometheus(namespace string, labels prometheus.Labels) *Prometheus {
+	return &Prometheus{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "operations_total",
+			Help:        "Requests sent to plc by operation and result.",
+			ConstLabels: labels,
+		}, []string{"operation", "result"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "operation_duration_seconds",
+			Help:        "Latency of the requests sent to plc by operation.",
+			ConstLabels: labels,
+			Buckets:     []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation"}),
+		splits: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "pdu_splits",
+			Help:        "Pdus a read or write call was divided into.",
+			ConstLabels: labels,
+			Buckets:     []float64{1, 2, 4, 8, 16, 32, 64, 128},
+		}, []string{"operation"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "bytes_total",
+			Help:        "Bytes of the pdus sent to and received from plc.",
+			ConstLabels: labels,
+		}, []string{"direction"}),
+		timeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "timeouts_total",
+			Help:        "Requests without response in time.",
+			ConstLabels: labels,
+		}),
+		rejects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "rejects_total",
+			Help:        "Requests rejected by plc or by the client.",
+			ConstLabels: labels,
+		}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "reconnects_total",
+			Help:        "Reconnect attempts after connection lose.",
+			ConstLabels: labels,
+		}),
+	}
+}
+
+func (p *Prometheus) Operation(operation string, latency time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	p.operations.WithLabelValues(operation, result).Inc()
+	p.latency.WithLabelValues(operation).Observe(latency.Seconds())
+}
+
+func (p *Prometheus) Splits(operation string, pdus int) {
+	p.splits.WithLabelValues(operation).Observe(float64(pdus))
+}
+
+func (p *Prometheus) BytesSent(n int) {
+	p.bytes.WithLabelValues("sent").Add(float64(n))
+}
+
+func (p *Prometheus) BytesReceived(n int) {
+	p.bytes.WithLabelValues("received").Add(float64(n))
+}
+
+func (p *Prometheus) Timeout() {
+	p.timeouts.Inc()
+}
+
+func (p *Prometheus) Reject() {
+	p.rejects.Inc()
+}
+
+func (p *Prometheus) Reconnect() {
+	p.reconnects.Inc()
+}
+
+// Describe implements prometheus.Collector
+func (p *Prometheus) Describe(ch chan<- *prometheus.Desc) {
+	p.operations.Describe(ch)
+	p.latency.Describe(ch)
+	p.splits.Describe(ch)
+	p.bytes.Describe(ch)
+	p.timeouts.Describe(ch)
+	p.rejects.Describe(ch)
+	p.reconnects.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (p *Prometheus) Collect(ch chan<- prometheus.Metric) {
+	p.operations.Collect(ch)
+	p.latency.Collect(ch)
+	p.splits.Collect(ch)
+	p.bytes.Collect(ch)
+	p.timeouts.Collect(ch)
+	p.rejects.Collect(ch)
+	p.reconnects.Collect(ch)
+}
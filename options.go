@@ -0,0 +1,83 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+// WriteMode how the items of a batch write are merged and sent
+type WriteMode byte
+
+const (
+	// WmOrdered items are written in the given order, also across pdu splits.
+	// Only items following each other with contiguous addresses are merged,
+	// the pdus are sent one by one and the rest is not written after a failed item.
+	// Use it for handshakes, e.g. data written before the trigger bit
+	WmOrdered WriteMode = iota
+	// WmFastest items are sorted by address and all contiguous byte writes are merged,
+	// the order of the items is not guaranteed
+	WmFastest
+)
+
+func (m WriteMode) String() string {
+	switch m {
+	case WmOrdered:
+		return "Ordered"
+	case WmFastest:
+		return "Fastest"
+	default:
+		return "UnKnown"
+	}
+}
+
+// CallOption option of a single call, overrides the option of the client
+type CallOption func(o *callOptions)
+
+type callOptions struct {
+	writeMode WriteMode
+	verify    bool
+	rollback  bool
+	priority  Priority
+}
+
+// WithWriteMode write mode of the batch write
+func WithWriteMode(mode WriteMode) CallOption {
+	return func(o *callOptions) {
+		o.writeMode = mode
+	}
+}
+
+// WithVerify read back the written addresses and compare byte for byte,
+// a mismatch is reported as common.VerifyError
+func WithVerify(verify bool) CallOption {
+	return func(o *callOptions) {
+		o.verify = verify
+	}
+}
+
+// WithRollback journaled write, the original data of the addresses is read before the write
+// and written back best-effort if any address fails, reported as common.RollbackError
+func WithRollback(rollback bool) CallOption {
+	return func(o *callOptions) {
+		o.rollback = rollback
+	}
+}
+
+// WithPriority priority class of the requests of the call, see Priority
+func WithPriority(priority Priority) CallOption {
+	return func(o *callOptions) {
+		o.priority = priority
+	}
+}
+
+// callOptions options of the call, defaults from the client
+func (c *client) callOptions(opts []CallOption) callOptions {
+	o := callOptions{
+		writeMode: c.writeMode,
+		verify:    c.verifyWrites,
+		priority:  c.priority,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
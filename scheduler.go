@@ -0,0 +1,147 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"sync"
+)
+
+// Priority priority class of the requests of a call
+type Priority byte
+
+const (
+	// PrControl time critical jobs, e.g. interlock reads
+	PrControl Priority = iota
+	// PrInteractive jobs waited for by users, default of reads and writes
+	PrInteractive
+	// PrPolling cyclic scans
+	PrPolling
+	// PrBulk large transfers, default of UploadFile, DownloadFile, DBGet and DBFill
+	PrBulk
+
+	priorityCount = int(PrBulk) + 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PrControl:
+		return "Control"
+	case PrInteractive:
+		return "Interactive"
+	case PrPolling:
+		return "Polling"
+	case PrBulk:
+		return "Bulk"
+	default:
+		return "UnKnown"
+	}
+}
+
+// scheduler limits the jobs in flight on the connection to the slots negotiated with plc,
+// waiting jobs are started by priority, first in first out within a priority, no goroutine waits for a slot.
+// Large reads, writes and transfers are sent pdu by pdu, so jobs of higher priority are interleaved between their chunks
+type scheduler struct {
+	m       sync.Mutex
+	slots   int
+	running int
+	queues  [priorityCount][]job
+}
+
+// job waiting job, started when a slot is free or cancelled when the connection is closed
+type job struct {
+	start  func()
+	cancel func(err error)
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{slots: 1}
+}
+
+// setSlots max jobs in flight, the max amq caller negotiated on connect
+func (s *scheduler) setSlots(slots int) {
+	s.m.Lock()
+	s.slots = max(slots, 1)
+	// start the waiting jobs fitting into the new slots
+	starts := make([]func(), 0)
+	for s.running < s.slots {
+		start := s.next()
+		if start == nil {
+			break
+		}
+		starts = append(starts, start)
+	}
+	s.m.Unlock()
+	for _, start := range starts {
+		start()
+	}
+}
+
+// schedule call start when a slot is free for the job of the priority, now or by the release of another job,
+// or cancel if the job is still waiting when the connection is closed.
+// start must not block, the job releases the slot when finished
+func (s *scheduler) schedule(p Priority, start func(), cancel func(err error)) {
+	if int(p) >= priorityCount {
+		p = PrBulk
+	}
+	s.m.Lock()
+	if s.running < s.slots && s.waiting() == 0 {
+		s.running++
+		s.m.Unlock()
+		start()
+		return
+	}
+	s.queues[p] = append(s.queues[p], job{start: start, cancel: cancel})
+	s.m.Unlock()
+}
+
+// cancel cancel all waiting jobs with the error, the running jobs keep their slots until released
+func (s *scheduler) cancel(err error) {
+	s.m.Lock()
+	jobs := make([]job, 0, s.waiting())
+	for p := range s.queues {
+		jobs = append(jobs, s.queues[p]...)
+		s.queues[p] = nil
+	}
+	s.m.Unlock()
+	for _, j := range jobs {
+		j.cancel(err)
+	}
+}
+
+// release free the slot of a finished job, the slot is handed over to the next waiting job
+func (s *scheduler) release() {
+	s.m.Lock()
+	s.running--
+	var start func()
+	if s.running < s.slots {
+		start = s.next()
+	}
+	s.m.Unlock()
+	if start != nil {
+		start()
+	}
+}
+
+// next take the first waiting job of the highest priority and its slot, nil if none, must be locked
+func (s *scheduler) next() func() {
+	for p := range s.queues {
+		if len(s.queues[p]) > 0 {
+			start := s.queues[p][0].start
+			s.queues[p][0] = job{}
+			s.queues[p] = s.queues[p][1:]
+			s.running++
+			return start
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) waiting() int {
+	n := 0
+	for p := range s.queues {
+		n += len(s.queues[p])
+	}
+	return n
+}
@@ -5,10 +5,10 @@
 package gs7
 
 import (
-	"github.com/panjf2000/gnet/v2"
 	"github.com/shiyuecamus/gs7/common"
 	"github.com/shiyuecamus/gs7/core"
 	"github.com/shiyuecamus/gs7/logging"
+	"github.com/shiyuecamus/gs7/metrics"
 	"github.com/shiyuecamus/gs7/util"
 	"sync"
 	"time"
@@ -19,31 +19,29 @@ const (
 	minPduSize    = 1
 )
 
+// s7TcpClient dispatch the frames received on the connection of a client to the contexts of the requests
 type s7TcpClient struct {
-	*gnet.BuiltinEventEngine
 	logger            logging.Logger
-	eng               gnet.Engine
+	metrics           metrics.Metrics
 	requestContextMap sync.Map
 	isoConnectChan    chan RequestContext
 	isoDisconnectChan chan RequestContext
-	onOpen            OnOpen
 	onClose           OnClose
 	validate          PduValidate
 	// Connect
 	timeout time.Duration
 }
 
-type OnOpen func(c gnet.Conn)
-type OnClose func(c gnet.Conn, err error)
+type OnClose func(err error)
 type PduValidate func(tpkt common.TPKT) error
 
-func newTcpClient(logger logging.Logger, timeout time.Duration,
-	onOpen OnOpen, onClose OnClose, pduValidate PduValidate) *s7TcpClient {
+func newTcpClient(logger logging.Logger, metrics metrics.Metrics, timeout time.Duration,
+	onClose OnClose, pduValidate PduValidate) *s7TcpClient {
 	return &s7TcpClient{
 		logger:            logger,
+		metrics:           metrics,
 		isoConnectChan:    make(chan RequestContext, 1),
 		isoDisconnectChan: make(chan RequestContext, 1),
-		onOpen:            onOpen,
 		onClose:           onClose,
 		validate:          pduValidate,
 		timeout:           timeout,
@@ -60,6 +58,7 @@ func (t *s7TcpClient) handleRequestContext(context RequestContext) (err error) {
 	time.AfterFunc(t.timeout, func() {
 		ctx, ok := t.requestContextMap.LoadAndDelete(context.GetRequestId())
 		if ok {
+			t.metrics.Timeout()
 			ctx.(RequestContext).PutError(common.ErrorWithCode(common.ErrTcpRequestTimeout))
 		}
 	})
@@ -77,6 +76,7 @@ func (t *s7TcpClient) handleConnectRequestContext(context RequestContext) (err e
 	time.AfterFunc(t.timeout, func() {
 		select {
 		case reqCtx := <-t.isoConnectChan:
+			t.metrics.Timeout()
 			reqCtx.PutError(common.ErrorWithCode(common.ErrTcpRequestTimeout))
 			break
 		default:
@@ -97,6 +97,7 @@ func (t *s7TcpClient) handleDisconnectRequestContext(context RequestContext) (er
 	time.AfterFunc(t.timeout, func() {
 		select {
 		case reqCtx := <-t.isoDisconnectChan:
+			t.metrics.Timeout()
 			reqCtx.PutError(common.ErrorWithCode(common.ErrTcpRequestTimeout))
 			break
 		default:
@@ -106,48 +107,54 @@ func (t *s7TcpClient) handleDisconnectRequestContext(context RequestContext) (er
 	return
 }
 
-func (t *s7TcpClient) OnBoot(eng gnet.Engine) (action gnet.Action) {
-	t.logger.Infof("S7 tcp client on boot.")
-	t.eng = eng
-	return
+// removeRequestContext remove the context of a request not written, connect contexts are left to their timeout
+func (t *s7TcpClient) removeRequestContext(context RequestContext) {
+	if _, ok := context.(*StandardRequestContext); ok {
+		t.requestContextMap.CompareAndDelete(context.GetRequestId(), context)
+	}
 }
 
-func (t *s7TcpClient) OnShutdown(gnet.Engine) {
-	t.logger.Infof("S7 tcp client on shutdown.")
+// removeRequestId remove the context of the pdu reference, its response is discarded if it still arrives
+func (t *s7TcpClient) removeRequestId(requestId uint16) {
+	t.requestContextMap.Delete(requestId)
 }
 
-func (t *s7TcpClient) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
-	t.logger.Infof("S7 tcp client connection [%s] did open", c.RemoteAddr().String())
-	util.Invoke(t.onOpen, []interface{}{(*gnet.Conn)(nil)}, c)
-	return
+// failRequestContexts fail the contexts of all requests in flight with the error, e.g. the connection is closed
+func (t *s7TcpClient) failRequestContexts(err error) {
+	t.requestContextMap.Range(func(key, _ any) bool {
+		if ctx, ok := t.requestContextMap.LoadAndDelete(key); ok {
+			ctx.(RequestContext).PutError(err)
+		}
+		return true
+	})
+	for _, ch := range []chan RequestContext{t.isoConnectChan, t.isoDisconnectChan} {
+		select {
+		case ctx := <-ch:
+			ctx.PutError(err)
+		default:
+		}
+	}
 }
 
-func (t *s7TcpClient) OnClose(c gnet.Conn, err error) (action gnet.Action) {
-	t.logger.Infof("S7 tcp client connection [%s] did closed with error: %v", c.RemoteAddr().String(), err)
-	util.Invoke(t.onClose, []interface{}{(*gnet.Conn)(nil), (*error)(nil)}, c, err)
-	return
+// OnClose the connection is closed, implements FrameHandler
+func (t *s7TcpClient) OnClose(err error) {
+	util.Invoke(t.onClose, []interface{}{(*error)(nil)}, err)
 }
 
-func (t *s7TcpClient) OnTraffic(c gnet.Conn) (action gnet.Action) {
-	tpktBuf, err := c.Next(common.TpktLen)
-	if tpktBuf == nil || err != nil {
-		t.logger.Warnf("S7 tcp client received invalid package")
-		return
-	}
-	tpkt, err := core.TPKTFromBytes(tpktBuf)
+// OnFrame dispatch the response to the context of the request, implements FrameHandler
+func (t *s7TcpClient) OnFrame(total []byte) {
+	tpkt, err := core.TPKTFromBytes(total)
 	if err != nil {
 		t.logger.Warnf("S7 tcp client parse tpkt failed with error: [%v]", err)
 		return
 	}
-
-	nextBuf, err := c.Next(int(tpkt.GetLength()) - common.TpktLen)
-	if nextBuf == nil || err != nil {
-		t.logger.Warnf("S7 tcp client parse package main failed with error: [%v]", err)
-		return
-	}
-	total := append(tpktBuf, nextBuf...)
+	t.metrics.BytesReceived(len(total))
 	t.logger.Debugf("S7 client received: % x", total)
 	ack, err := core.DataFromBytes(total)
+	if ack == nil {
+		t.logger.Warnf("S7 tcp client parse package failed with error: [%v]", err)
+		return
+	}
 	var ctx RequestContext
 
 	switch ack.GetCOTP().GetPduType() {
@@ -162,6 +169,7 @@ func (t *s7TcpClient) OnTraffic(c gnet.Conn) (action gnet.Action) {
 	case common.PtReject:
 		select {
 		case ctx = <-t.isoConnectChan:
+			t.metrics.Reject()
 			ctx.PutError(common.ErrorWithCode(common.ErrTcpRequestRejected))
 			return
 		default:
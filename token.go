@@ -88,6 +88,8 @@ const (
 	TtBlockInfo
 	TtClockRead
 	TtBaseRead
+	TtWrite
+	TtBatchResult
 )
 
 func NewToken(tt TokenType) TokenCompleter {
@@ -132,6 +134,10 @@ func NewToken(tt TokenType) TokenCompleter {
 		return &ClockReadToken{baseToken[time.Time]{complete: make(chan struct{})}}
 	case TtBaseRead:
 		return &BaseReadToken{baseToken[[]byte]{complete: make(chan struct{})}}
+	case TtWrite:
+		return &WriteToken{baseToken[[]common.ReturnCode]{complete: make(chan struct{})}}
+	case TtBatchResult:
+		return &BatchResultToken{baseToken[[]ItemResult]{complete: make(chan struct{})}}
 	default:
 		return nil
 	}
@@ -193,6 +199,8 @@ type baseToken[V interface{}] struct {
 	complete chan struct{}
 	err      error
 	v        V
+	// next continuations of then, run on completion
+	next []func()
 }
 
 func (b *baseToken[V]) Wait() (V, error) {
@@ -227,19 +235,42 @@ func (b *baseToken[V]) Done() <-chan struct{} {
 	return b.complete
 }
 
+// then call next on completion by the completing goroutine, or now if completed, without a goroutine of its own.
+// next must not block, unlike the ack of Async
+func (b *baseToken[V]) then(next func(v V, err error)) {
+	b.m.Lock()
+	select {
+	case <-b.complete:
+		b.m.Unlock()
+		next(b.v, b.err)
+	default:
+		b.next = append(b.next, func() { next(b.v, b.err) })
+		b.m.Unlock()
+	}
+}
+
 func (b *baseToken[V]) setError(e error) {
 	b.m.Lock()
 	b.err = e
-	b.flowComplete()
 	b.m.Unlock()
+	b.flowComplete()
 }
 
 func (b *baseToken[V]) flowComplete() {
+	b.m.Lock()
 	select {
 	case <-b.complete:
+		b.m.Unlock()
+		return
 	default:
 		close(b.complete)
 	}
+	next := b.next
+	b.next = nil
+	b.m.Unlock()
+	for _, fn := range next {
+		fn()
+	}
 }
 
 type ConnectToken struct {
@@ -302,6 +333,10 @@ type BaseReadToken struct {
 	baseToken[[]byte]
 }
 
+type WriteToken struct {
+	baseToken[[]common.ReturnCode]
+}
+
 type SingleRawReadToken struct {
 	baseToken[RawInfo]
 }
@@ -317,3 +352,7 @@ type SingleParsedReadToken struct {
 type BatchParsedReadToken struct {
 	baseToken[[]any]
 }
+
+type BatchResultToken struct {
+	baseToken[[]ItemResult]
+}
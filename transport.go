@@ -0,0 +1,121 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/shiyuecamus/gs7/common"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport dial the connections of clients and receive the tpkt frames of them
+type Transport interface {
+	// Dial connect to the address
+	Dial(address string, timeout time.Duration) (net.Conn, error)
+	// Attach receive the frames of the connection until it is closed, requests are written to the returned connection
+	Attach(conn net.Conn, handler FrameHandler) (net.Conn, error)
+	// Close stop the transport and close the attached connections
+	Close() error
+}
+
+// FrameHandler handle the frames received by a transport on a connection
+type FrameHandler interface {
+	// OnFrame a complete tpkt frame, owned by the handler
+	OnFrame(frame []byte)
+	// OnClose the connection is closed, err is nil if closed locally
+	OnClose(err error)
+}
+
+// netTransport plain net.Conn, a goroutine reads the frames of each connection
+type netTransport struct {
+	m      sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewNetTransport transport of plain net.Conn without event loops, e.g. for constrained environments
+func NewNetTransport() Transport {
+	return &netTransport{conns: make(map[net.Conn]struct{})}
+}
+
+func (t *netTransport) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, timeout)
+}
+
+func (t *netTransport) Attach(conn net.Conn, handler FrameHandler) (net.Conn, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.closed {
+		_ = conn.Close()
+		return nil, net.ErrClosed
+	}
+	t.conns[conn] = struct{}{}
+	go t.serve(conn, handler)
+	return conn, nil
+}
+
+func (t *netTransport) serve(conn net.Conn, handler FrameHandler) {
+	err := readFrames(conn, handler)
+	t.m.Lock()
+	delete(t.conns, conn)
+	t.m.Unlock()
+	_ = conn.Close()
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) {
+		err = nil
+	}
+	handler.OnClose(err)
+}
+
+func (t *netTransport) Close() error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.closed = true
+	for conn := range t.conns {
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// readFrames read the tpkt frames of the connection until it fails
+func readFrames(conn net.Conn, handler FrameHandler) error {
+	header := make([]byte, common.TpktLen)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < common.TpktLen {
+			return common.ErrorWithCode(common.ErrCliResponseInvalid)
+		}
+		frame := make([]byte, length)
+		copy(frame, header)
+		if _, err := io.ReadFull(conn, frame[common.TpktLen:]); err != nil {
+			return err
+		}
+		handler.OnFrame(frame)
+	}
+}
+
+// pipeTransport in-memory connections of net.Pipe
+type pipeTransport struct {
+	*netTransport
+	serve func(conn net.Conn)
+}
+
+// NewPipeTransport transport of in-memory connections of net.Pipe, the other end of each dialed connection
+// is passed to serve, e.g. a fake plc in tests without sockets
+func NewPipeTransport(serve func(conn net.Conn)) Transport {
+	return &pipeTransport{netTransport: NewNetTransport().(*netTransport), serve: serve}
+}
+
+func (t *pipeTransport) Dial(string, time.Duration) (net.Conn, error) {
+	client, server := net.Pipe()
+	go t.serve(server)
+	return client, nil
+}
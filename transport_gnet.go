@@ -0,0 +1,118 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"encoding/binary"
+	"github.com/panjf2000/gnet/v2"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/logging"
+	"net"
+	"sync"
+	"time"
+)
+
+// gnetTransport connections served by the event loops of a gnet client
+type gnetTransport struct {
+	*gnet.BuiltinEventEngine
+	logger logging.Logger
+	cli    *gnet.Client
+	// fallback serve the connections without file descriptor, e.g. ssh tunnels
+	fallback *netTransport
+	// handlers frame handlers of the enrolled connections by local address, until opened
+	handlers sync.Map
+}
+
+// NewGnetTransport transport of the event loops of a gnet client, one per cpu core
+func NewGnetTransport(logger logging.Logger) (Transport, error) {
+	t := &gnetTransport{logger: logger, fallback: NewNetTransport().(*netTransport)}
+	cli, err := gnet.NewClient(t, gnet.WithLogger(logger), gnet.WithMulticore(true))
+	if err != nil {
+		return nil, err
+	}
+	if err = cli.Start(); err != nil {
+		return nil, err
+	}
+	t.cli = cli
+	return t, nil
+}
+
+func (t *gnetTransport) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, timeout)
+}
+
+func (t *gnetTransport) Attach(conn net.Conn, handler FrameHandler) (net.Conn, error) {
+	if _, ok := conn.(*net.TCPConn); !ok {
+		return t.fallback.Attach(conn, handler)
+	}
+	key := conn.LocalAddr().String()
+	t.handlers.Store(key, handler)
+	gc, err := t.cli.Enroll(conn)
+	if err != nil {
+		t.handlers.Delete(key)
+		return nil, err
+	}
+	return gc, nil
+}
+
+func (t *gnetTransport) Close() error {
+	_ = t.fallback.Close()
+	return t.cli.Stop()
+}
+
+func (t *gnetTransport) OnBoot(gnet.Engine) (action gnet.Action) {
+	t.logger.Infof("S7 tcp client on boot.")
+	return
+}
+
+func (t *gnetTransport) OnShutdown(gnet.Engine) {
+	t.logger.Infof("S7 tcp client on shutdown.")
+}
+
+func (t *gnetTransport) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
+	t.logger.Infof("S7 tcp client connection [%s] did open", c.RemoteAddr().String())
+	if handler, ok := t.handlers.LoadAndDelete(c.LocalAddr().String()); ok {
+		c.SetContext(handler)
+	}
+	return
+}
+
+func (t *gnetTransport) OnClose(c gnet.Conn, err error) (action gnet.Action) {
+	t.logger.Infof("S7 tcp client connection [%s] did closed with error: %v", c.RemoteAddr().String(), err)
+	if handler, ok := c.Context().(FrameHandler); ok {
+		handler.OnClose(err)
+	}
+	return
+}
+
+func (t *gnetTransport) OnTraffic(c gnet.Conn) (action gnet.Action) {
+	handler, ok := c.Context().(FrameHandler)
+	if !ok {
+		_, _ = c.Discard(c.InboundBuffered())
+		return
+	}
+	// all complete frames, the rest waits for more data
+	for c.InboundBuffered() >= common.TpktLen {
+		header, err := c.Peek(common.TpktLen)
+		if err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[2:]))
+		if length < common.TpktLen {
+			t.logger.Warnf("S7 tcp client received invalid package")
+			return gnet.Close
+		}
+		if c.InboundBuffered() < length {
+			return
+		}
+		buf, err := c.Next(length)
+		if err != nil {
+			return
+		}
+		// the buffer of gnet is reused
+		handler.OnFrame(append([]byte{}, buf...))
+	}
+	return
+}
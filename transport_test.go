@@ -0,0 +1,154 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package gs7
+
+import (
+	"bytes"
+	"github.com/shiyuecamus/gs7/common"
+	"github.com/shiyuecamus/gs7/core"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPduLength pdu length negotiated by the test plc, small enough to split the requests of the tests
+const testPduLength = 240
+
+// testPlc fake plc of data blocks, answering connect, read and write requests
+type testPlc struct {
+	m      sync.Mutex
+	blocks map[uint16][]byte
+	reads  int
+	writes int
+}
+
+func newTestPlc() *testPlc {
+	return &testPlc{blocks: make(map[uint16][]byte)}
+}
+
+// block memory of the data block, created on first use
+func (p *testPlc) block(number uint16) []byte {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.blockLocked(number)
+}
+
+func (p *testPlc) blockLocked(number uint16) []byte {
+	if p.blocks[number] == nil {
+		p.blocks[number] = make([]byte, 1024)
+	}
+	return p.blocks[number]
+}
+
+// serve answer the frames of the connection until it is closed
+func (p *testPlc) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	header := make([]byte, common.TpktLen)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		frame := make([]byte, int(header[2])<<8|int(header[3]))
+		copy(frame, header)
+		if _, err := io.ReadFull(conn, frame[common.TpktLen:]); err != nil {
+			return
+		}
+		request, err := core.DataFromBytes(frame)
+		if err != nil {
+			return
+		}
+		if _, err = conn.Write(p.answer(request).ToBytes()); err != nil {
+			return
+		}
+	}
+}
+
+// answer the response of the request
+func (p *testPlc) answer(request *core.PDU) *core.PDU {
+	if cotp, ok := request.COTP.(*core.COTPConnection); ok {
+		response := &core.PDU{TPKT: core.NewTPKT(), COTP: core.NewCOTPConnectionForConfirm(cotp)}
+		response.SelfCheck()
+		return response
+	}
+	response := &core.PDU{
+		TPKT: core.NewTPKT(),
+		COTP: core.NewCOTPData(),
+		Header: &core.AckHeader{
+			ProtocolId:   0x32,
+			MessageType:  common.MtAckData,
+			Reserved:     []byte{0x00, 0x00},
+			PduReference: request.Header.GetPduReference(),
+			ErrorCode:    []byte{0x00}, // written behind ErrorClass
+		},
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	switch parameter := request.Parameter.(type) {
+	case *core.SetupComParameter:
+		setup := *parameter
+		setup.PduLength = min(setup.PduLength, testPduLength)
+		response.Parameter = &setup
+	case *core.ReadWriteParameter:
+		response.Parameter = core.NewAckReadWriteParameter(parameter)
+		items := make([]common.ResponseItem, 0, len(parameter.RequestItems))
+		for i, item := range parameter.RequestItems {
+			item := item.(*core.StandardRequestItem)
+			memory := p.blockLocked(item.DbNumber)[item.ByteAddress:]
+			if parameter.FunctionCode == common.FcRead {
+				size := int(item.Count) * int(item.VariableType.Size())
+				items = append(items, core.NewAckDataItem(bytes.Clone(memory[:size]), common.DvtByteWordDword))
+				continue
+			}
+			copy(memory, request.Datum.(*core.ReadWriteDatum).ReturnItems[i].(*core.DataItem).Data)
+			items = append(items, core.NewReturnItem(common.RcSuccess))
+		}
+		if parameter.FunctionCode == common.FcRead {
+			p.reads++
+		} else {
+			p.writes++
+		}
+		response.Datum = core.NewReadWriteDatum(items)
+	}
+	response.SelfCheck()
+	return response
+}
+
+func TestPipeTransport(t *testing.T) {
+	plc := newTestPlc()
+	c := NewClientBuilder().Transport(NewPipeTransport(plc.serve)).PlcType(common.S1500).Timeout(2 * time.Second).Build()
+	if _, err := c.Connect().Wait(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if c.GetPduLength() != testPduLength {
+		t.Fatalf("pdu length %d, want %d", c.GetPduLength(), testPduLength)
+	}
+
+	data := make([]byte, 600)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := c.WriteRaw("DB1.B0[600]", data).Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plc.block(1)[:600], data) {
+		t.Fatal("written bytes differ")
+	}
+	raw, err := c.ReadRaw("DB1.B0[600]").Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw.Value, data) {
+		t.Fatal("read bytes differ")
+	}
+
+	plc.m.Lock()
+	defer plc.m.Unlock()
+	if plc.reads < 2 || plc.writes < 2 {
+		t.Fatalf("%d reads and %d writes, want the requests split by the pdu length", plc.reads, plc.writes)
+	}
+}
@@ -11,23 +11,31 @@ import (
 	"github.com/shiyuecamus/gs7/common"
 	"github.com/shiyuecamus/gs7/util"
 	"github.com/spf13/cast"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
-	"io"
+	"math"
 	"time"
 	"unicode/utf16"
 )
 
 type RawInfo struct {
-	Value   []byte
-	Type    common.ParamVariableType
+	Value []byte
+	Type  common.ParamVariableType
+	// Count element count of array address, e.g. DB1.REAL0[100]
+	// 0 means single value
+	Count   int
 	plcType common.PlcType
+	// charset encoding of STRING and CHAR arrays
+	charset common.Charset
+	// bitOffset bit address of the first element of bit array
+	bitOffset int
 }
 
 func (r RawInfo) Parse() (res any, err error) {
+	if r.Count > 0 {
+		return r.parseArray()
+	}
 	switch r.Type {
 	case common.PvtString:
-		return StringFromBytes(r.Value, r.plcType)
+		return StringFromBytesWithCharset(r.Value, r.plcType, r.charset)
 	case common.PvtWString:
 		return WStringFromBytes(r.Value, r.plcType)
 	case common.PvtBit:
@@ -62,12 +70,167 @@ func (r RawInfo) Parse() (res any, err error) {
 		return CounterFromBytes(r.Value)
 	case common.PvtTimer:
 		return TimerFromBytes(r.Value)
+	case common.PvtIecCounter, common.PvtIecTimer:
+		// current value of S7-200 timers (in time base ticks) and counters
+		return IntFromBytes(r.Value)
+	case common.PvtHsCounter:
+		return DIntFromBytes(r.Value)
+	case common.PvtSInt:
+		return SIntFromBytes(r.Value)
+	case common.PvtUSInt:
+		return USIntFromBytes(r.Value)
+	case common.PvtUInt:
+		return UIntFromBytes(r.Value)
+	case common.PvtUDInt:
+		return UDIntFromBytes(r.Value)
+	case common.PvtLInt:
+		return LIntFromBytes(r.Value)
+	case common.PvtULInt:
+		return ULIntFromBytes(r.Value)
+	case common.PvtLWord:
+		return LWordFromBytes(r.Value)
+	case common.PvtLReal:
+		return LRealFromBytes(r.Value)
+	case common.PvtLTime:
+		return LTimeFromBytes(r.Value)
+	case common.PvtLTimeOfDay:
+		return LTimeOfDayFromBytes(r.Value)
+	case common.PvtLDT:
+		return LDateTimeFromBytes(r.Value)
+	case common.PvtWChar:
+		return WCharFromBytes(r.Value)
 	default:
 		err = common.ErrorWithCode(common.ErrVariableTypeUnrecognized, r.Type)
 		return
 	}
 }
 
+// parseArray parse array value to slice of the element type, e.g. []Real, []Bit
+func (r RawInfo) parseArray() (res any, err error) {
+	switch r.Type {
+	case common.PvtBit:
+		if len(r.Value)*8 < r.bitOffset+r.Count {
+			err = errors.New("invalid bytes for Bit array")
+			return
+		}
+		bits := make([]Bit, r.Count)
+		for i := range bits {
+			bits[i] = Bit(util.GetBoolAt(r.Value[(r.bitOffset+i)/8], uint((r.bitOffset+i)%8)))
+		}
+		return bits, nil
+	case common.PvtByte:
+		return parseArray(r.Value, r.Count, r.Type, ByteFromBytes)
+	case common.PvtChar:
+		return parseArray(r.Value, r.Count, r.Type, CharFromBytes)
+	case common.PvtInt:
+		return parseArray(r.Value, r.Count, r.Type, IntFromBytes)
+	case common.PvtWord:
+		return parseArray(r.Value, r.Count, r.Type, WordFromBytes)
+	case common.PvtDInt:
+		return parseArray(r.Value, r.Count, r.Type, DIntFromBytes)
+	case common.PvtDWord:
+		return parseArray(r.Value, r.Count, r.Type, DWordFromBytes)
+	case common.PvtReal:
+		return parseArray(r.Value, r.Count, r.Type, RealFromBytes)
+	case common.PvtTime:
+		return parseArray(r.Value, r.Count, r.Type, TimeFromBytes)
+	case common.PvtDate:
+		return parseArray(r.Value, r.Count, r.Type, DateFromBytes)
+	case common.PvtTimeOfDay:
+		return parseArray(r.Value, r.Count, r.Type, TimeOfDayFromBytes)
+	case common.PvtDateTime:
+		return parseArray(r.Value, r.Count, r.Type, DateTimeFromBytes)
+	case common.PvtDTL:
+		return parseArray(r.Value, r.Count, r.Type, DateTimeLongFromBytes)
+	case common.PvtS5Time:
+		return parseArray(r.Value, r.Count, r.Type, S5TimeFromBytes)
+	case common.PvtSInt:
+		return parseArray(r.Value, r.Count, r.Type, SIntFromBytes)
+	case common.PvtUSInt:
+		return parseArray(r.Value, r.Count, r.Type, USIntFromBytes)
+	case common.PvtUInt:
+		return parseArray(r.Value, r.Count, r.Type, UIntFromBytes)
+	case common.PvtUDInt:
+		return parseArray(r.Value, r.Count, r.Type, UDIntFromBytes)
+	case common.PvtLInt:
+		return parseArray(r.Value, r.Count, r.Type, LIntFromBytes)
+	case common.PvtULInt:
+		return parseArray(r.Value, r.Count, r.Type, ULIntFromBytes)
+	case common.PvtLWord:
+		return parseArray(r.Value, r.Count, r.Type, LWordFromBytes)
+	case common.PvtLReal:
+		return parseArray(r.Value, r.Count, r.Type, LRealFromBytes)
+	case common.PvtLTime:
+		return parseArray(r.Value, r.Count, r.Type, LTimeFromBytes)
+	case common.PvtLTimeOfDay:
+		return parseArray(r.Value, r.Count, r.Type, LTimeOfDayFromBytes)
+	case common.PvtLDT:
+		return parseArray(r.Value, r.Count, r.Type, LDateTimeFromBytes)
+	case common.PvtWChar:
+		return parseArray(r.Value, r.Count, r.Type, WCharFromBytes)
+	default:
+		err = common.ErrorWithCode(common.ErrVariableTypeUnrecognized, r.Type)
+		return
+	}
+}
+
+// Text text of CHAR arrays, STRING and WSTRING, decoded in the charset of client,
+// trailing zeros of CHAR arrays are trimmed
+func (r RawInfo) Text() (string, error) {
+	switch {
+	case r.Type == common.PvtChar && r.Count > 0:
+		if len(r.Value) < r.Count {
+			return "", common.ErrorWithCode(common.ErrCliResponseLengthMismatch)
+		}
+		return r.charset.Decode(bytes.TrimRight(r.Value[:r.Count], "\x00"))
+	case r.Type == common.PvtString:
+		s, err := StringFromBytesWithCharset(r.Value, r.plcType, r.charset)
+		return string(s), err
+	case r.Type == common.PvtWString:
+		s, err := WStringFromBytes(r.Value, r.plcType)
+		return string(s), err
+	default:
+		return "", common.ErrorWithCode(common.ErrVariableTypeUnrecognized, r.Type)
+	}
+}
+
+// ItemResult result of an address in batch read or write
+type ItemResult struct {
+	// Address address or tag name of the item
+	Address string
+	// Raw raw info of the item, only for read
+	Raw RawInfo
+	// Value parsed value of the item, only for parsed read
+	Value any
+	// ReturnCode return code of the item from plc
+	// RcReserved if the item was not sent, e.g. invalid address
+	ReturnCode common.ReturnCode
+	// Err error of the item, nil if succeeded
+	Err error
+	// Restored the item was written back with the original data after a failed write, only for rollback
+	Restored bool
+}
+
+// Ok return the item succeeded
+func (r ItemResult) Ok() bool {
+	return r.Err == nil
+}
+
+func parseArray[T any](bs []byte, count int, t common.ParamVariableType, fromBytes func([]byte) (T, error)) (res []T, err error) {
+	size := int(t.Size())
+	if len(bs) < size*count {
+		err = common.ErrorWithCode(common.ErrCliResponseLengthMismatch)
+		return
+	}
+	res = make([]T, count)
+	for i := range res {
+		if res[i], err = fromBytes(bs[i*size : (i+1)*size]); err != nil {
+			return
+		}
+	}
+	return
+}
+
 type Bit bool
 
 func BitFromBytes(bs []byte) (b Bit, err error) {
@@ -431,6 +594,11 @@ func (s S5Time) String() string {
 type String string
 
 func StringFromBytes(bs []byte, plcType common.PlcType) (s String, err error) {
+	return StringFromBytesWithCharset(bs, plcType, common.CsGBK)
+}
+
+// StringFromBytesWithCharset decode string content of the charset
+func StringFromBytesWithCharset(bs []byte, plcType common.PlcType, charset common.Charset) (s String, err error) {
 	var minLen int
 	switch plcType {
 	case common.S200Smart:
@@ -444,34 +612,43 @@ func StringFromBytes(bs []byte, plcType common.PlcType) (s String, err error) {
 		err = errors.New("invalid bytes for String")
 		return
 	}
-	if len(bs) == minLen {
+	// the value may be read up to the max length, the length byte gives the actual content
+	sub := bs[minLen:]
+	if length := int(bs[minLen-1]); length < len(sub) {
+		sub = sub[:length]
+	}
+	if len(sub) == 0 {
 		s = ""
 		return
 	}
-	var sub []byte
-	if plcType == common.S200Smart {
-		sub = bs[minLen:]
-	} else {
-		sub = bs[minLen:]
-	}
-	reader := transform.NewReader(bytes.NewReader(sub), simplifiedchinese.GBK.NewDecoder())
-	var res []byte
-	res, err = io.ReadAll(reader)
+	var res string
+	res, err = charset.Decode(sub)
 	s = String(res)
 	return
 }
 
+// ToBytes encode string content in GBK, nil if the string has characters not in GBK, which fails the write.
+// Use Encode to get the error and to encode in the charset of the client
 func (s String) ToBytes(pduLength int) []byte {
-	bs := make([]byte, 0)
-	var buf bytes.Buffer
-	writer := transform.NewWriter(&buf, simplifiedchinese.GBK.NewEncoder())
-	_, _ = writer.Write([]byte(s))
-
-	bs = append(bs, strMaxLength(pduLength), byte(len(string(s))))
-	bs = append(bs, buf.Bytes()...)
+	bs, err := s.Encode(pduLength, common.CsGBK)
+	if err != nil {
+		return nil
+	}
 	return bs
 }
 
+// Encode encode string content in the charset, characters not in the charset are an error
+func (s String) Encode(pduLength int, charset common.Charset) ([]byte, error) {
+	content, err := charset.Encode(string(s))
+	if err != nil {
+		return nil, err
+	}
+	bs := make([]byte, 0, 2+len(content))
+	bs = append(bs, strMaxLength(pduLength), byte(len(content)))
+	bs = append(bs, content...)
+	return bs, nil
+}
+
 func (s String) String() string {
 	return "String[" + string(s) + "]"
 }
@@ -480,26 +657,32 @@ type WString string
 
 func WStringFromBytes(bs []byte, plcType common.PlcType) (s WString, err error) {
 	var minLen int
-	var length int
 	switch plcType {
 	case common.S200Smart:
 		minLen = 2
-		length = int(bs[minLen/2])
 		break
 	default:
 		minLen = 4
-		length = int(binary.BigEndian.Uint16(bs[minLen/2:]))
 		break
 	}
 	if len(bs) < minLen {
 		err = errors.New("invalid bytes for String")
 		return
 	}
+	var length int
+	if plcType == common.S200Smart {
+		length = int(bs[minLen/2])
+	} else {
+		length = int(binary.BigEndian.Uint16(bs[minLen/2:]))
+	}
 	if len(bs) == minLen {
 		s = ""
 		return
 	}
-	content := bs[minLen : minLen+int(length)*2]
+	content := bs[minLen:]
+	if length*2 < len(content) {
+		content = content[:length*2]
+	}
 	u16s := make([]uint16, len(content)/2)
 	for i := 0; i < len(u16s); i++ {
 		u16s[i] = binary.BigEndian.Uint16(bs[minLen+i*2:])
@@ -533,13 +716,13 @@ func CounterFromBytes(bs []byte) (c Counter, err error) {
 		err = errors.New("invalid bytes for Counter")
 		return
 	}
-	c = Counter((bs[0] << 8) | bs[1])
+	c = Counter(uint16(bs[0])<<8 | uint16(bs[1]))
 	return
 }
 
 func (c Counter) ToBytes() []byte {
 	bs := make([]byte, 2)
-	bs[0] = byte((uint16(c) << 8) & 0xFF)
+	bs[0] = byte(uint16(c) >> 8)
 	bs[1] = byte(uint16(c) & 0xFF)
 	return bs
 }
@@ -594,6 +777,242 @@ func (t Timer) String() string {
 	return "S5Time[" + cast.ToString(time.Duration(t).Milliseconds()) + "ms]"
 }
 
+type SInt int8
+
+func SIntFromBytes(bs []byte) (i SInt, err error) {
+	if len(bs) < 1 {
+		err = errors.New("invalid bytes for SInt")
+		return
+	}
+	i = SInt(int8(bs[0]))
+	return
+}
+
+func (i SInt) ToBytes() []byte {
+	return []byte{byte(i)}
+}
+
+func (i SInt) String() string {
+	return "SInt[" + cast.ToString(int8(i)) + "]"
+}
+
+type USInt uint8
+
+func USIntFromBytes(bs []byte) (i USInt, err error) {
+	if len(bs) < 1 {
+		err = errors.New("invalid bytes for USInt")
+		return
+	}
+	i = USInt(bs[0])
+	return
+}
+
+func (i USInt) ToBytes() []byte {
+	return []byte{byte(i)}
+}
+
+func (i USInt) String() string {
+	return "USInt[" + cast.ToString(uint8(i)) + "]"
+}
+
+type UInt uint16
+
+func UIntFromBytes(bs []byte) (i UInt, err error) {
+	if len(bs) < 2 {
+		err = errors.New("invalid bytes for UInt")
+		return
+	}
+	i = UInt(binary.BigEndian.Uint16(bs))
+	return
+}
+
+func (i UInt) ToBytes() []byte {
+	return util.NumberToBytes(uint16(i))
+}
+
+func (i UInt) String() string {
+	return "UInt[" + cast.ToString(uint16(i)) + "]"
+}
+
+type UDInt uint32
+
+func UDIntFromBytes(bs []byte) (i UDInt, err error) {
+	if len(bs) < 4 {
+		err = errors.New("invalid bytes for UDInt")
+		return
+	}
+	i = UDInt(binary.BigEndian.Uint32(bs))
+	return
+}
+
+func (i UDInt) ToBytes() []byte {
+	return util.NumberToBytes(uint32(i))
+}
+
+func (i UDInt) String() string {
+	return "UDInt[" + cast.ToString(uint32(i)) + "]"
+}
+
+type LInt int64
+
+func LIntFromBytes(bs []byte) (i LInt, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LInt")
+		return
+	}
+	i = LInt(int64(binary.BigEndian.Uint64(bs)))
+	return
+}
+
+func (i LInt) ToBytes() []byte {
+	return util.NumberToBytes(int64(i))
+}
+
+func (i LInt) String() string {
+	return "LInt[" + cast.ToString(int64(i)) + "]"
+}
+
+type ULInt uint64
+
+func ULIntFromBytes(bs []byte) (i ULInt, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for ULInt")
+		return
+	}
+	i = ULInt(binary.BigEndian.Uint64(bs))
+	return
+}
+
+func (i ULInt) ToBytes() []byte {
+	return util.NumberToBytes(uint64(i))
+}
+
+func (i ULInt) String() string {
+	return "ULInt[" + cast.ToString(uint64(i)) + "]"
+}
+
+type LWord uint64
+
+func LWordFromBytes(bs []byte) (w LWord, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LWord")
+		return
+	}
+	w = LWord(binary.BigEndian.Uint64(bs))
+	return
+}
+
+func (w LWord) ToBytes() []byte {
+	return util.NumberToBytes(uint64(w))
+}
+
+func (w LWord) String() string {
+	return "LWord[" + cast.ToString(uint64(w)) + "]"
+}
+
+type LReal float64
+
+func LRealFromBytes(bs []byte) (r LReal, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LReal")
+		return
+	}
+	r = LReal(math.Float64frombits(binary.BigEndian.Uint64(bs)))
+	return
+}
+
+func (r LReal) ToBytes() []byte {
+	return util.NumberToBytes(float64(r))
+}
+
+func (r LReal) String() string {
+	return "LReal[" + cast.ToString(float64(r)) + "]"
+}
+
+// LTime duration in nanoseconds
+type LTime time.Duration
+
+func LTimeFromBytes(bs []byte) (t LTime, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LTime")
+		return
+	}
+	t = LTime(int64(binary.BigEndian.Uint64(bs)))
+	return
+}
+
+func (t LTime) ToBytes() []byte {
+	return util.NumberToBytes(int64(t))
+}
+
+func (t LTime) String() string {
+	return "LTime[" + time.Duration(t).String() + "]"
+}
+
+// LTimeOfDay nanoseconds since midnight
+type LTimeOfDay time.Time
+
+func LTimeOfDayFromBytes(bs []byte) (t LTimeOfDay, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LTimeOfDay")
+		return
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	t = LTimeOfDay(today.Add(time.Duration(binary.BigEndian.Uint64(bs))))
+	return
+}
+
+func (tod LTimeOfDay) ToBytes() []byte {
+	t := time.Time(tod)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return util.NumberToBytes(uint64(t.Sub(start).Nanoseconds()))
+}
+
+func (tod LTimeOfDay) String() string {
+	return "LTimeOfDay[" + time.Time(tod).Format("15:04:05.000000000") + "]"
+}
+
+// LDateTime DATE_AND_LTIME(LDT), nanoseconds since 1970-01-01 00:00:00
+type LDateTime time.Time
+
+func LDateTimeFromBytes(bs []byte) (d LDateTime, err error) {
+	if len(bs) < 8 {
+		err = errors.New("invalid bytes for LDateTime")
+		return
+	}
+	d = LDateTime(time.Unix(0, int64(binary.BigEndian.Uint64(bs))).UTC())
+	return
+}
+
+func (d LDateTime) ToBytes() []byte {
+	return util.NumberToBytes(time.Time(d).UnixNano())
+}
+
+func (d LDateTime) String() string {
+	return "LDateTime[" + time.Time(d).Format("2006-01-02 15:04:05.000000000") + "]"
+}
+
+// WChar utf-16 character
+type WChar rune
+
+func WCharFromBytes(bs []byte) (c WChar, err error) {
+	if len(bs) < 2 {
+		err = errors.New("invalid bytes for WChar")
+		return
+	}
+	c = WChar(binary.BigEndian.Uint16(bs))
+	return
+}
+
+func (c WChar) ToBytes() []byte {
+	return util.NumberToBytes(uint16(c))
+}
+
+func (c WChar) String() string {
+	return "WChar[" + string(rune(c)) + "]"
+}
+
 func strMaxLength(pduLength int) uint8 {
 	if pduLength >= 480 {
 		return 254
@@ -0,0 +1,180 @@
+// Copyright 2024 shiyuecamus. All Rights Reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (x xlsxText) String() string {
+	if len(x.Runs) == 0 {
+		return x.T
+	}
+	var sb strings.Builder
+	for _, r := range x.Runs {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXlsxSheet read all rows of a worksheet from xlsx content as strings
+// if sheet is empty or not found, the first worksheet is read
+func ReadXlsxSheet(r io.ReaderAt, size int64, sheet string) (rows [][]string, err error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err = decodeXlsxPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return
+	}
+	if len(workbook.Sheets) == 0 {
+		err = fmt.Errorf("xlsx workbook has no sheets")
+		return
+	}
+	var rels xlsxRelationships
+	if err = decodeXlsxPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return
+	}
+	id := workbook.Sheets[0].Id
+	for _, s := range workbook.Sheets {
+		if strings.EqualFold(s.Name, sheet) {
+			id = s.Id
+			break
+		}
+	}
+	var target string
+	for _, rel := range rels.Relationships {
+		if rel.Id == id {
+			target = rel.Target
+			break
+		}
+	}
+	if target == "" {
+		err = fmt.Errorf("xlsx worksheet relationship [%s] not found", id)
+		return
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err = decodeXlsxPart(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return
+		}
+	}
+	var worksheet xlsxWorksheet
+	if err = decodeXlsxPart(files, target, &worksheet); err != nil {
+		return
+	}
+
+	rows = make([][]string, 0, len(worksheet.Rows))
+	for _, row := range worksheet.Rows {
+		values := make([]string, 0, len(row.Cells))
+		for i, cell := range row.Cells {
+			col := xlsxColumnIndex(cell.Ref)
+			if col < 0 {
+				col = i
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+			switch cell.Type {
+			case "s":
+				var index int
+				if _, err = fmt.Sscanf(cell.Value, "%d", &index); err != nil || index < 0 || index >= len(shared.Items) {
+					err = fmt.Errorf("xlsx shared string [%s] of cell [%s] is invalid", cell.Value, cell.Ref)
+					return
+				}
+				values[col] = shared.Items[index].String()
+			case "inlineStr":
+				values[col] = cell.Inline.String()
+			default:
+				values[col] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+	return
+}
+
+func decodeXlsxPart(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx part [%s] not found", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// xlsxColumnIndex convert cell reference column letters to zero based index, e.g. A1 -> 0, AB12 -> 27
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}